@@ -2,6 +2,12 @@
 package action
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/devaloi/watchdog/internal/watcher"
@@ -12,22 +18,125 @@ type Action interface {
 	Execute(ev watcher.Event) error
 }
 
-// TemplateData is passed to command and log templates.
+// Result describes a finished command run.
+type Result struct {
+	ExitCode int
+	Duration time.Duration
+	Err      error
+	// Canceled is set when the run was killed because the action was
+	// re-triggered or stopped.
+	Canceled bool
+}
+
+// TemplateData is passed to command and log templates. Ext, Stem, Rel and
+// Abs are derived from the path; Size, ModTime and Mode describe the file
+// when the template is rendered and are zero if it no longer exists. Vars
+// holds the config's vars. SHA256 and the Git methods are computed only
+// when a template uses them.
 type TemplateData struct {
-	Path  string
-	Event string
-	Dir   string
-	Name  string
-	Time  string
+	Path    string
+	Event   string
+	Dir     string
+	Name    string
+	Time    string
+	Ext     string
+	Stem    string
+	Rel     string
+	Abs     string
+	Size    int64
+	ModTime string
+	Mode    string
+	Vars    map[string]string
 }
 
 // NewTemplateData builds template data from a watcher event.
 func NewTemplateData(ev watcher.Event) TemplateData {
-	return TemplateData{
+	ext := filepath.Ext(ev.Name)
+
+	d := TemplateData{
 		Path:  ev.Path,
 		Event: string(ev.Type),
 		Dir:   ev.Dir,
 		Name:  ev.Name,
 		Time:  time.Now().Format(time.RFC3339),
+		Ext:   ext,
+		Stem:  strings.TrimSuffix(ev.Name, ext),
+		Rel:   ev.Path,
+		Abs:   ev.Path,
+	}
+
+	abs, err := filepath.Abs(ev.Path)
+	if err == nil {
+		d.Abs = abs
+	}
+
+	wd, err := os.Getwd()
+	if err == nil {
+		rel, relErr := filepath.Rel(wd, d.Abs)
+		if relErr == nil {
+			d.Rel = rel
+		}
+	}
+
+	info, err := os.Stat(ev.Path)
+	if err == nil {
+		d.Size = info.Size()
+		d.ModTime = info.ModTime().Format(time.RFC3339)
+		d.Mode = info.Mode().String()
+	}
+
+	return d
+}
+
+// SHA256 returns the hex SHA-256 of the file's content, or "" if it
+// cannot be read.
+func (d TemplateData) SHA256() string {
+	f, err := os.Open(d.Path)
+	if err != nil {
+		return ""
 	}
+
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return ""
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// GitRoot returns the work tree containing the file, or "" outside a
+// repository.
+func (d TemplateData) GitRoot() string {
+	root, _ := gitRepo(d.Dir)
+
+	return root
+}
+
+// GitBranch returns the checked-out branch of the file's repository, or
+// "" when HEAD is detached or there is no repository.
+func (d TemplateData) GitBranch() string {
+	_, gitDir := gitRepo(d.Dir)
+	if gitDir == "" {
+		return ""
+	}
+
+	branch, _ := gitHead(gitDir)
+
+	return branch
+}
+
+// GitCommit returns the commit HEAD points at in the file's repository.
+func (d TemplateData) GitCommit() string {
+	_, gitDir := gitRepo(d.Dir)
+	if gitDir == "" {
+		return ""
+	}
+
+	_, commit := gitHead(gitDir)
+
+	return commit
 }
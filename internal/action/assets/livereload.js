@@ -0,0 +1,57 @@
+// watchdog live-reload client. Include with:
+//   <script src="http://localhost:35729/livereload.js"></script>
+(function () {
+  "use strict";
+
+  var script = document.currentScript;
+  var base = script ? new URL(script.src).origin : "http://localhost:35729";
+  var source = new EventSource(base + "/livereload");
+
+  function basename(path) {
+    return path.split(/[\\/]/).pop().split("?")[0];
+  }
+
+  function swap(link) {
+    var url = new URL(link.href, location.href);
+    url.searchParams.set("livereload", Date.now().toString(36));
+
+    // Insert the new sheet before removing the old one so the page never
+    // renders unstyled.
+    var next = link.cloneNode();
+    next.href = url.toString();
+    next.addEventListener("load", function () {
+      link.remove();
+    });
+    next.addEventListener("error", function () {
+      next.remove();
+    });
+    link.after(next);
+  }
+
+  function reloadCSS(path) {
+    var links = Array.prototype.slice.call(
+      document.querySelectorAll('link[rel="stylesheet"][href]')
+    );
+    var name = basename(path);
+    var matched = links.filter(function (link) {
+      return basename(new URL(link.href, location.href).pathname) === name;
+    });
+
+    // A changed partial or an unlinked file may affect any sheet.
+    (matched.length > 0 ? matched : links).forEach(swap);
+  }
+
+  source.addEventListener("message", function (e) {
+    var msg = JSON.parse(e.data);
+
+    if (msg.command !== "reload") {
+      return;
+    }
+
+    if (msg.css) {
+      reloadCSS(msg.path);
+    } else {
+      location.reload();
+    }
+  });
+})();
@@ -1,42 +1,51 @@
 package action
 
 import (
-	"bytes"
 	"context"
 	"io"
 	"os"
 	"os/exec"
 	"sync"
-	"text/template"
+	"time"
 
 	"github.com/devaloi/watchdog/internal/watcher"
 )
 
-// CommandAction runs a shell command when triggered.
+// CommandAction runs a command when triggered.
 // It kills any previously running instance before starting a new one.
 type CommandAction struct {
-	CmdTemplate string
-	Dir         string
-	DryRun      bool
-	Output      io.Writer
-
-	mu      sync.Mutex
-	cancel  context.CancelFunc
-	running *exec.Cmd
+	Command *Command
+	Dir     string
+	DryRun  bool
+	Output  io.Writer
+	// ErrOutput receives stderr; if nil, stderr goes to Output.
+	ErrOutput io.Writer
+
+	// OnExit, if set, is called with the result of every started command
+	// once it exits. It runs on its own goroutine and must not call back
+	// into the CommandAction.
+	OnExit func(Result)
+
+	// Vars are the config's vars, available to the template as .Vars.
+	Vars map[string]string
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
 }
 
-// NewCommandAction creates a CommandAction with the given command template and working directory.
-func NewCommandAction(cmdTemplate, dir string) *CommandAction {
+// NewCommandAction creates a CommandAction with the given parsed command and working directory.
+func NewCommandAction(cmd *Command, dir string) *CommandAction {
 	return &CommandAction{
-		CmdTemplate: cmdTemplate,
-		Dir:         dir,
-		Output:      os.Stdout,
+		Command: cmd,
+		Dir:     dir,
+		Output:  os.Stdout,
 	}
 }
 
 // Execute kills any running previous command and starts the command with template variables.
 func (c *CommandAction) Execute(ev watcher.Event) error {
-	rendered, err := renderTemplate(c.CmdTemplate, NewTemplateData(ev))
+	argv, err := c.Command.Argv(ev, c.Vars)
 	if err != nil {
 		return err
 	}
@@ -53,14 +62,29 @@ func (c *CommandAction) Execute(ev watcher.Event) error {
 	ctx, cancel := context.WithCancel(context.Background())
 	c.cancel = cancel
 
-	cmd := exec.CommandContext(ctx, "sh", "-c", rendered) //nolint:gosec // user-configured command
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...) //nolint:gosec // user-configured command
 	cmd.Dir = c.Dir
 	cmd.Stdout = c.Output
+
 	cmd.Stderr = c.Output
+	if c.ErrOutput != nil {
+		cmd.Stderr = c.ErrOutput
+	}
+
+	err = cmd.Start()
+	if err != nil {
+		cancel()
+		c.cancel = nil
+
+		return err
+	}
 
-	c.running = cmd
+	done := make(chan struct{})
+	c.done = done
 
-	return cmd.Start()
+	go c.wait(ctx, cmd, time.Now(), done)
+
+	return nil
 }
 
 // Stop kills any running command.
@@ -77,24 +101,33 @@ func (c *CommandAction) killPrevious() {
 		c.cancel = nil
 	}
 
-	if c.running != nil && c.running.Process != nil {
-		_ = c.running.Wait()
-		c.running = nil
+	if c.done != nil {
+		<-c.done
+		c.done = nil
 	}
 }
 
-func renderTemplate(tmpl string, data TemplateData) (string, error) {
-	t, err := template.New("cmd").Parse(tmpl)
-	if err != nil {
-		return "", err
-	}
+func (c *CommandAction) wait(ctx context.Context, cmd *exec.Cmd, start time.Time, done chan struct{}) {
+	defer close(done)
 
-	var buf bytes.Buffer
+	err := cmd.Wait()
 
-	err = t.Execute(&buf, data)
-	if err != nil {
-		return "", err
+	flush(cmd.Stdout)
+	flush(cmd.Stderr)
+
+	if c.OnExit != nil {
+		c.OnExit(Result{
+			ExitCode: cmd.ProcessState.ExitCode(),
+			Duration: time.Since(start),
+			Err:      err,
+			Canceled: ctx.Err() != nil,
+		})
 	}
+}
 
-	return buf.String(), nil
+// flush writes out any partial line held by a line-buffered writer.
+func flush(w io.Writer) {
+	if f, ok := w.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
 }
@@ -2,6 +2,8 @@ package action
 
 import (
 	"bytes"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 	"time"
@@ -9,10 +11,21 @@ import (
 	"github.com/devaloi/watchdog/internal/watcher"
 )
 
+func mustCommand(t *testing.T, command string, args ...string) *Command {
+	t.Helper()
+
+	cmd, err := ParseCommand(command, args, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cmd
+}
+
 func TestCommandActionExecute(t *testing.T) {
 	var buf bytes.Buffer
 
-	cmd := NewCommandAction("echo hello", ".")
+	cmd := NewCommandAction(mustCommand(t, "echo hello"), ".")
 	cmd.Output = &buf
 
 	ev := watcher.Event{Path: "main.go", Type: watcher.Modify, Name: "main.go", Dir: "."}
@@ -34,7 +47,7 @@ func TestCommandActionExecute(t *testing.T) {
 func TestCommandActionTemplateVars(t *testing.T) {
 	var buf bytes.Buffer
 
-	cmd := NewCommandAction("echo {{.Path}} {{.Event}} {{.Name}} {{.Dir}}", ".")
+	cmd := NewCommandAction(mustCommand(t, "echo {{.Path}} {{.Event}} {{.Name}} {{.Dir}}"), ".")
 	cmd.Output = &buf
 
 	ev := watcher.Event{Path: "src/main.go", Type: watcher.Create, Name: "main.go", Dir: "src"}
@@ -56,7 +69,7 @@ func TestCommandActionTemplateVars(t *testing.T) {
 }
 
 func TestCommandActionKillsPrevious(t *testing.T) {
-	cmd := NewCommandAction("sleep 10", ".")
+	cmd := NewCommandAction(mustCommand(t, "sleep 10"), ".")
 
 	ev := watcher.Event{Path: "main.go", Type: watcher.Modify, Name: "main.go", Dir: "."}
 
@@ -77,7 +90,7 @@ func TestCommandActionKillsPrevious(t *testing.T) {
 func TestCommandActionDryRun(t *testing.T) {
 	var buf bytes.Buffer
 
-	cmd := NewCommandAction("echo should-not-run", ".")
+	cmd := NewCommandAction(mustCommand(t, "echo should-not-run"), ".")
 	cmd.Output = &buf
 	cmd.DryRun = true
 
@@ -94,3 +107,161 @@ func TestCommandActionDryRun(t *testing.T) {
 		t.Errorf("expected no output in dry run, got %q", buf.String())
 	}
 }
+
+func TestCommandActionOnExit(t *testing.T) {
+	results := make(chan Result, 1)
+
+	cmd := NewCommandAction(mustCommand(t, "exit 3"), ".")
+	cmd.OnExit = func(res Result) {
+		results <- res
+	}
+
+	ev := watcher.Event{Path: "main.go", Type: watcher.Modify, Name: "main.go", Dir: "."}
+
+	err := cmd.Execute(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case res := <-results:
+		if res.ExitCode != 3 {
+			t.Errorf("exit code = %d, want 3", res.ExitCode)
+		}
+
+		if res.Canceled {
+			t.Error("expected run not to be marked canceled")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("timed out waiting for OnExit")
+	}
+}
+
+func TestCommandActionOnExitCanceled(t *testing.T) {
+	results := make(chan Result, 2)
+
+	cmd := NewCommandAction(mustCommand(t, "sleep 10"), ".")
+	cmd.OnExit = func(res Result) {
+		results <- res
+	}
+
+	ev := watcher.Event{Path: "main.go", Type: watcher.Modify, Name: "main.go", Dir: "."}
+
+	err := cmd.Execute(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd.Stop()
+
+	res := <-results
+	if !res.Canceled {
+		t.Error("expected stopped run to be marked canceled")
+	}
+}
+
+type flushWriter struct {
+	bytes.Buffer
+	flushed chan struct{}
+}
+
+func (f *flushWriter) Flush() error {
+	select {
+	case f.flushed <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func TestCommandActionSeparateStderr(t *testing.T) {
+	stdout := &flushWriter{flushed: make(chan struct{}, 1)}
+
+	var stderr bytes.Buffer
+
+	cmd := NewCommandAction(mustCommand(t, "echo out; echo err >&2"), ".")
+	cmd.Output = stdout
+	cmd.ErrOutput = &stderr
+
+	ev := watcher.Event{Path: "main.go", Type: watcher.Modify, Name: "main.go", Dir: "."}
+
+	err := cmd.Execute(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-stdout.flushed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected output to be flushed when the command exits")
+	}
+
+	cmd.Stop()
+
+	if strings.TrimSpace(stdout.String()) != "out" {
+		t.Errorf("stdout = %q, want %q", stdout.String(), "out")
+	}
+
+	if strings.TrimSpace(stderr.String()) != "err" {
+		t.Errorf("stderr = %q, want %q", stderr.String(), "err")
+	}
+}
+
+func TestCommandActionQuotesValues(t *testing.T) {
+	dir := t.TempDir()
+
+	var buf bytes.Buffer
+
+	cmd := NewCommandAction(mustCommand(t, "printf '[%s]' {{.Path}} {{.Name | raw}}"), dir)
+	cmd.Output = &buf
+
+	done := make(chan Result, 1)
+	cmd.OnExit = func(res Result) { done <- res }
+
+	ev := watcher.Event{Path: "a b/$(touch pwned).go", Type: watcher.Modify, Name: "x y", Dir: "a b"}
+
+	err := cmd.Execute(ev)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-done
+
+	if buf.String() != "[a b/$(touch pwned).go][x][y]" {
+		t.Errorf("output = %q", buf.String())
+	}
+
+	_, err = os.Stat(filepath.Join(dir, "pwned"))
+	if err == nil {
+		t.Error("file name was run as a command substitution")
+	}
+}
+
+func TestCommandActionArgs(t *testing.T) {
+	var buf bytes.Buffer
+
+	cmd := NewCommandAction(mustCommand(t, "", "printf", "[%s]", "{{.Path}}", "{{.Vars.mode}}"), ".")
+	cmd.Vars = map[string]string{"mode": "fast"}
+	cmd.Output = &buf
+
+	done := make(chan Result, 1)
+	cmd.OnExit = func(res Result) { done <- res }
+
+	err := cmd.Execute(watcher.Event{Path: "it's $HOME.go", Type: watcher.Modify, Name: "it's $HOME.go", Dir: "."})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	<-done
+
+	if buf.String() != "[it's $HOME.go][fast]" {
+		t.Errorf("output = %q", buf.String())
+	}
+
+	cmd = NewCommandAction(mustCommand(t, "", "{{.Vars.missing}}"), ".")
+
+	err = cmd.Execute(watcher.Event{Path: "a.go", Type: watcher.Modify})
+	if err == nil {
+		t.Error("expected error for an empty program name")
+	}
+}
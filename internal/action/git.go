@@ -0,0 +1,112 @@
+package action
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// gitRepo locates the repository containing dir by reading .git directly,
+// without running git. It returns the work tree root and the git
+// directory, or empty strings outside a repository.
+func gitRepo(dir string) (root, gitDir string) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", ""
+	}
+
+	for d := abs; ; d = filepath.Dir(d) {
+		dotGit := filepath.Join(d, ".git")
+
+		info, statErr := os.Stat(dotGit)
+		if statErr == nil && info.IsDir() {
+			return d, dotGit
+		}
+
+		// Worktrees and submodules have a .git file pointing elsewhere.
+		if statErr == nil {
+			data, readErr := os.ReadFile(dotGit) //nolint:gosec // path inside the watched tree
+			if readErr == nil {
+				target, ok := strings.CutPrefix(strings.TrimSpace(string(data)), "gitdir: ")
+				if ok {
+					if !filepath.IsAbs(target) {
+						target = filepath.Join(d, target)
+					}
+
+					return d, target
+				}
+			}
+		}
+
+		if filepath.Dir(d) == d {
+			return "", ""
+		}
+	}
+}
+
+// gitHead returns the checked-out branch, empty when HEAD is detached,
+// and the commit HEAD points at.
+func gitHead(gitDir string) (branch, commit string) {
+	data, err := os.ReadFile(filepath.Join(gitDir, "HEAD")) //nolint:gosec // path inside the watched tree
+	if err != nil {
+		return "", ""
+	}
+
+	head := strings.TrimSpace(string(data))
+
+	ref, ok := strings.CutPrefix(head, "ref: ")
+	if !ok {
+		return "", head
+	}
+
+	return strings.TrimPrefix(ref, "refs/heads/"), resolveRef(gitDir, ref)
+}
+
+// resolveRef reads a ref from its loose file or from packed-refs. A
+// linked worktree keeps branches in the main repository's git directory.
+func resolveRef(gitDir, ref string) string {
+	dirs := []string{gitDir}
+
+	common, err := os.ReadFile(filepath.Join(gitDir, "commondir")) //nolint:gosec // path inside the watched tree
+	if err == nil {
+		c := strings.TrimSpace(string(common))
+		if !filepath.IsAbs(c) {
+			c = filepath.Join(gitDir, c)
+		}
+
+		dirs = append(dirs, c)
+	}
+
+	for _, d := range dirs {
+		data, readErr := os.ReadFile(filepath.Join(d, filepath.FromSlash(ref))) //nolint:gosec // path inside the watched tree
+		if readErr == nil {
+			return strings.TrimSpace(string(data))
+		}
+
+		if sha := packedRef(filepath.Join(d, "packed-refs"), ref); sha != "" {
+			return sha
+		}
+	}
+
+	return ""
+}
+
+func packedRef(path, ref string) string {
+	f, err := os.Open(path) //nolint:gosec // path inside the watched tree
+	if err != nil {
+		return ""
+	}
+
+	defer func() { _ = f.Close() }()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		sha, name, ok := strings.Cut(sc.Text(), " ")
+		if ok && name == ref {
+			return sha
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,221 @@
+package action
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+// DefaultLiveReloadAddr is the conventional LiveReload port.
+const DefaultLiveReloadAddr = "127.0.0.1:35729"
+
+const (
+	liveReloadShutdownTimeout = 5 * time.Second
+	liveReloadHeaderTimeout   = 5 * time.Second
+	liveReloadKeepAlive       = 15 * time.Second
+)
+
+//go:embed assets/livereload.js
+var liveReloadJS []byte
+
+// LiveReloadMessage is sent to browsers on every trigger. CSS is set when
+// the changed file is a stylesheet, so the client swaps stylesheets
+// instead of reloading the page.
+type LiveReloadMessage struct {
+	Command string `json:"command"`
+	Path    string `json:"path"`
+	CSS     bool   `json:"css"`
+}
+
+// LiveReloadServer serves the browser client at /livereload.js and pushes
+// reload messages to connected browsers over Server-Sent Events at
+// /livereload. Rules sharing an address share one server.
+type LiveReloadServer struct {
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+	done    chan struct{}
+	closed  bool
+}
+
+// NewLiveReloadServer creates a LiveReloadServer with no clients.
+func NewLiveReloadServer() *LiveReloadServer {
+	return &LiveReloadServer{
+		clients: make(map[chan []byte]struct{}),
+		done:    make(chan struct{}),
+	}
+}
+
+// Handler returns the server's http.Handler.
+func (s *LiveReloadServer) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /livereload.js", s.handleScript)
+	mux.HandleFunc("GET /livereload", s.handleEvents)
+
+	return mux
+}
+
+// Serve listens on addr until ctx is canceled.
+func (s *LiveReloadServer) Serve(ctx context.Context, addr string) error {
+	ln, err := (&net.ListenConfig{}).Listen(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Handler: s.Handler(), ReadHeaderTimeout: liveReloadHeaderTimeout}
+
+	go func() {
+		<-ctx.Done()
+
+		s.close()
+
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), liveReloadShutdownTimeout)
+		defer cancel()
+
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	err = srv.Serve(ln)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// Reload pushes a reload message for path to every connected browser and
+// returns how many received it.
+func (s *LiveReloadServer) Reload(path string) int {
+	msg, _ := json.Marshal(LiveReloadMessage{
+		Command: "reload",
+		Path:    filepath.ToSlash(path),
+		CSS:     IsStylesheet(path),
+	})
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sent := 0
+
+	for ch := range s.clients {
+		select {
+		case ch <- msg:
+			sent++
+		default:
+		}
+	}
+
+	return sent
+}
+
+// Clients returns the number of connected browsers.
+func (s *LiveReloadServer) Clients() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.clients)
+}
+
+// IsStylesheet reports whether path is a CSS file that can be hot-swapped.
+func IsStylesheet(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".css")
+}
+
+func (s *LiveReloadServer) handleScript(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	_, _ = w.Write(liveReloadJS)
+}
+
+func (s *LiveReloadServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+
+		return
+	}
+
+	ch := make(chan []byte, 8)
+
+	s.mu.Lock()
+	s.clients[ch] = struct{}{}
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, ch)
+		s.mu.Unlock()
+	}()
+
+	// Pages are served from another origin, typically a dev server.
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(liveReloadKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.done:
+			return
+		case <-keepAlive.C:
+			_, err := w.Write([]byte(": keep-alive\n\n"))
+			if err != nil {
+				return
+			}
+		case msg := <-ch:
+			_, err := w.Write([]byte("data: " + string(msg) + "\n\n"))
+			if err != nil {
+				return
+			}
+		}
+
+		flusher.Flush()
+	}
+}
+
+func (s *LiveReloadServer) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+}
+
+// LiveReloadAction tells connected browsers to reload when triggered.
+type LiveReloadAction struct {
+	Server *LiveReloadServer
+	DryRun bool
+}
+
+// NewLiveReloadAction creates a LiveReloadAction pushing to server.
+func NewLiveReloadAction(server *LiveReloadServer) *LiveReloadAction {
+	return &LiveReloadAction{Server: server}
+}
+
+// Execute sends a reload message for the event's path. Having no
+// connected browsers is not an error.
+func (l *LiveReloadAction) Execute(ev watcher.Event) error {
+	if l.DryRun {
+		return nil
+	}
+
+	l.Server.Reload(ev.Path)
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package action
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+func TestLiveReloadScript(t *testing.T) {
+	srv := httptest.NewServer(NewLiveReloadServer().Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/livereload.js") //nolint:noctx // test request
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, readErr := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if readErr != nil {
+		t.Fatal(readErr)
+	}
+
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/javascript") {
+		t.Errorf("Content-Type = %q", resp.Header.Get("Content-Type"))
+	}
+
+	if !strings.Contains(string(body), "EventSource") {
+		t.Error("script does not open an EventSource")
+	}
+}
+
+func TestLiveReloadActionExecute(t *testing.T) {
+	server := NewLiveReloadServer()
+
+	srv := httptest.NewServer(server.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/livereload") //nolint:noctx // test request
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if resp.Header.Get("Access-Control-Allow-Origin") != "*" {
+		t.Error("expected a CORS header for cross-origin pages")
+	}
+
+	if server.Clients() != 1 {
+		t.Fatalf("clients = %d, want 1", server.Clients())
+	}
+
+	lr := NewLiveReloadAction(server)
+	reader := bufio.NewReader(resp.Body)
+
+	tests := []struct {
+		path string
+		css  bool
+	}{
+		{"assets/site.css", true},
+		{"templates/index.html", false},
+		{"assets/THEME.CSS", true},
+	}
+
+	for _, tt := range tests {
+		err := lr.Execute(watcher.Event{Path: tt.path, Type: watcher.Modify})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		msg := readLiveReload(t, reader)
+		if msg.Command != "reload" || msg.Path != tt.path || msg.CSS != tt.css {
+			t.Errorf("message for %s = %+v, want css=%v", tt.path, msg, tt.css)
+		}
+	}
+}
+
+func TestLiveReloadActionDryRun(t *testing.T) {
+	server := NewLiveReloadServer()
+	ch := make(chan []byte, 1)
+	server.clients[ch] = struct{}{}
+
+	lr := NewLiveReloadAction(server)
+	lr.DryRun = true
+
+	err := lr.Execute(watcher.Event{Path: "index.html", Type: watcher.Modify})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case msg := <-ch:
+		t.Errorf("dry run sent %s", msg)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func readLiveReload(t *testing.T, r *bufio.Reader) LiveReloadMessage {
+	t.Helper()
+
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: ")
+		if !ok {
+			continue
+		}
+
+		var msg LiveReloadMessage
+
+		err = json.Unmarshal([]byte(data), &msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return msg
+	}
+}
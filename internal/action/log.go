@@ -1,22 +1,22 @@
 package action
 
 import (
-	"bytes"
 	"io"
-	"text/template"
 
 	"github.com/devaloi/watchdog/internal/watcher"
 )
 
 // LogAction writes formatted log lines when triggered.
 type LogAction struct {
-	Format string
+	Format *Template
 	Output io.Writer
 	DryRun bool
+	// Vars are the config's vars, available to the template as .Vars.
+	Vars map[string]string
 }
 
-// NewLogAction creates a LogAction with the given format template.
-func NewLogAction(format string, output io.Writer) *LogAction {
+// NewLogAction creates a LogAction with the given parsed format template.
+func NewLogAction(format *Template, output io.Writer) *LogAction {
 	return &LogAction{
 		Format: format,
 		Output: output,
@@ -30,22 +30,14 @@ func (l *LogAction) Execute(ev watcher.Event) error {
 	}
 
 	data := NewTemplateData(ev)
+	data.Vars = l.Vars
 
-	t, err := template.New("log").Parse(l.Format)
+	line, err := l.Format.Render(data)
 	if err != nil {
 		return err
 	}
 
-	var buf bytes.Buffer
-
-	err = t.Execute(&buf, data)
-	if err != nil {
-		return err
-	}
-
-	buf.WriteString("\n")
-
-	_, err = l.Output.Write(buf.Bytes())
+	_, err = io.WriteString(l.Output, line+"\n")
 
 	return err
 }
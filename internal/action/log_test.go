@@ -8,10 +8,21 @@ import (
 	"github.com/devaloi/watchdog/internal/watcher"
 )
 
+func mustTemplate(t *testing.T, src string) *Template {
+	t.Helper()
+
+	tmpl, err := ParseTemplate(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tmpl
+}
+
 func TestLogActionExecute(t *testing.T) {
 	var buf bytes.Buffer
 
-	la := NewLogAction("{{.Event}} {{.Path}}", &buf)
+	la := NewLogAction(mustTemplate(t, "{{.Event}} {{.Path}}"), &buf)
 
 	ev := watcher.Event{Path: "main.go", Type: watcher.Modify, Name: "main.go", Dir: "."}
 
@@ -29,7 +40,7 @@ func TestLogActionExecute(t *testing.T) {
 func TestLogActionTemplateVars(t *testing.T) {
 	var buf bytes.Buffer
 
-	la := NewLogAction("[{{.Time}}] {{.Event}} {{.Name}} in {{.Dir}}", &buf)
+	la := NewLogAction(mustTemplate(t, "[{{.Time}}] {{.Event}} {{.Name}} in {{.Dir}}"), &buf)
 
 	ev := watcher.Event{Path: "src/app.go", Type: watcher.Create, Name: "app.go", Dir: "src"}
 
@@ -49,7 +60,7 @@ func TestLogActionTemplateVars(t *testing.T) {
 func TestLogActionDryRun(t *testing.T) {
 	var buf bytes.Buffer
 
-	la := NewLogAction("{{.Event}} {{.Path}}", &buf)
+	la := NewLogAction(mustTemplate(t, "{{.Event}} {{.Path}}"), &buf)
 	la.DryRun = true
 
 	ev := watcher.Event{Path: "main.go", Type: watcher.Modify, Name: "main.go", Dir: "."}
@@ -0,0 +1,371 @@
+package action
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+// Shells are the shells a command string can run in; "" means sh.
+var Shells = []string{"sh", "bash", "zsh", "pwsh"}
+
+// shellArgv returns the argv that runs script in shell.
+func shellArgv(shell, script string) []string {
+	switch shell {
+	case "", "sh":
+		return []string{"sh", "-c", script}
+	case "pwsh":
+		return []string{"pwsh", "-NoProfile", "-NonInteractive", "-Command", script}
+	default:
+		return []string{shell, "-c", script}
+	}
+}
+
+// ParseCommandTemplate parses a command string for shell. Every value the
+// template prints is quoted as a single word for that shell, so a file
+// named "$(rm -rf ~).go" is passed along rather than run. Output already
+// piped through shellquote, pwshquote or raw is left alone. A value the
+// command string already puts inside quotes or a here-document is an
+// error, since quoting it there would not protect it. A value in a
+// comment has its line breaks replaced, so it cannot end the comment.
+func ParseCommandTemplate(src, shell string) (*Template, error) {
+	t, err := ParseTemplate(src)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the escaper emits these, so they are not among the funcs a
+	// config can use.
+	t.t.Funcs(template.FuncMap{
+		"pwshcall":    func(v any) string { return "& " + PwshQuote(fmt.Sprint(v)) },
+		"commentsafe": func(v any) string { return lineBreaks.Replace(fmt.Sprint(v)) },
+	})
+
+	e := &escaper{quote: "shellquote", escape: '\\', posix: true, cmdPos: true}
+	if shell == "pwsh" {
+		e.quote, e.call, e.escape, e.posix = "pwshquote", "pwshcall", '`', false
+	}
+
+	for _, tt := range t.t.Templates() {
+		if tt.Tree != nil {
+			e.tree = tt.Tree
+			e.walk(tt.Root)
+		}
+	}
+
+	if e.err != nil {
+		return nil, e.err
+	}
+
+	return t, nil
+}
+
+// escaper appends a quoting function to each printing action of a parse
+// tree, walking it in source order.
+type escaper struct {
+	tree  *parse.Tree
+	quote string
+
+	// call, if set, quotes a value in command position. PowerShell takes
+	// a quoted word there as a string, not a command, so it needs &.
+	call   string
+	cmdPos bool
+
+	// open is the quote the text so far leaves open, or 0. escape is the
+	// shell's escape character outside single quotes. posix shells also
+	// have here-documents.
+	open   byte
+	escape byte
+	posix  bool
+	err    error
+
+	// prev is the last byte scanned; a # after a word boundary starts a
+	// comment, which runs to the end of the line.
+	prev    byte
+	comment bool
+
+	// docs are the here-documents opened by << on the current line, whose
+	// bodies follow it in order; inDoc is set while reading docs[0]'s.
+	docs  []heredoc
+	inDoc bool
+	line  []byte
+}
+
+// heredoc is a here-document's delimiter and whether <<- strips leading
+// tabs from its lines.
+type heredoc struct {
+	word  string
+	strip bool
+}
+
+// lineBreaks replaces the line breaks that would end a comment.
+var lineBreaks = strings.NewReplacer("\r", " ", "\n", " ")
+
+func (e *escaper) walk(n parse.Node) {
+	switch n := n.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+
+		for _, c := range n.Nodes {
+			e.walk(c)
+		}
+	case *parse.TextNode:
+		e.scan(n.Text)
+
+		// A command starts the script, or follows ;, |, && or ||. Values
+		// inside ( or { may be expressions, so they are left as strings.
+		text := strings.TrimRight(string(n.Text), " \t")
+		if text != "" {
+			e.cmdPos = strings.ContainsRune(";|\n", rune(text[len(text)-1])) || strings.HasSuffix(text, "&&")
+		}
+	case *parse.IfNode:
+		e.walk(n.List)
+		e.walk(n.ElseList)
+	case *parse.RangeNode:
+		e.walk(n.List)
+		e.walk(n.ElseList)
+	case *parse.WithNode:
+		e.walk(n.List)
+		e.walk(n.ElseList)
+	case *parse.ActionNode:
+		e.action(n.Pipe)
+	}
+}
+
+func (e *escaper) action(p *parse.PipeNode) {
+	// {{$x := ...}} prints nothing.
+	if len(p.Decl) > 0 || len(p.Cmds) == 0 {
+		return
+	}
+
+	cmdPos := e.cmdPos
+	e.cmdPos = false
+	e.prev = 'x'
+
+	last := p.Cmds[len(p.Cmds)-1]
+	if id, ok := last.Args[0].(*parse.IdentifierNode); ok && slices.Contains([]string{"shellquote", "pwshquote", "raw"}, id.Ident) {
+		return
+	}
+
+	switch {
+	case e.err != nil:
+	case e.inDoc:
+		// The shell expands $(...) in a here-document even inside quotes,
+		// and a line of the value could end it early.
+		e.err = errors.New("action: {{" + p.String() + "}} is inside a here-document, where quoting does not protect it; pass the value as an argument instead")
+	case e.open != 0:
+		// The quoted value would end the user's quotes and open new ones.
+		e.err = errors.New("action: {{" + p.String() + "}} is inside " + string(e.open) + " quotes; template values are quoted automatically, so remove the quotes or pipe the value through raw")
+	}
+
+	quote := e.quote
+
+	switch {
+	case e.comment:
+		quote = "commentsafe"
+	case cmdPos && e.call != "":
+		quote = e.call
+	}
+
+	p.Cmds = append(p.Cmds, &parse.CommandNode{
+		NodeType: parse.NodeCommand,
+		Pos:      p.Pos,
+		Args:     []parse.Node{parse.NewIdentifier(quote).SetTree(e.tree).SetPos(p.Pos)},
+	})
+}
+
+// scan tracks which quote, comment or here-document, if any, text leaves
+// open.
+func (e *escaper) scan(text []byte) {
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+
+		switch {
+		case e.inDoc:
+			e.docLine(c)
+		case e.comment:
+			if c == '\n' {
+				e.comment = false
+				e.newline()
+			}
+		case e.open == '\'':
+			if c == '\'' {
+				e.open = 0
+			}
+		case c == e.escape:
+			i++
+		case e.open == '"':
+			if c == '"' {
+				e.open = 0
+			}
+		case c == '\'' || c == '"':
+			e.open = c
+		case c == '#' && (e.prev == 0 || strings.IndexByte(" \t\n;&|()", e.prev) >= 0):
+			e.comment = true
+		case c == '<' && e.posix:
+			i = e.heredoc(text, i)
+		case c == '\n':
+			e.newline()
+		}
+
+		e.prev = text[min(i, len(text)-1)]
+	}
+}
+
+// heredoc records the here-document opened by a << at text[i], returning
+// the index of the last byte of the operator and its delimiter.
+func (e *escaper) heredoc(text []byte, i int) int {
+	if !bytes.HasPrefix(text[i:], []byte("<<")) || bytes.HasPrefix(text[i:], []byte("<<<")) {
+		return i
+	}
+
+	j := i + 2
+	doc := heredoc{strip: j < len(text) && text[j] == '-'}
+
+	if doc.strip {
+		j++
+	}
+
+	for j < len(text) && (text[j] == ' ' || text[j] == '\t') {
+		j++
+	}
+
+	start := j
+	for j < len(text) && !bytes.ContainsRune([]byte(" \t\n;&|<>()"), rune(text[j])) {
+		j++
+	}
+
+	// Quoting any part of the delimiter only stops expansion in the body.
+	doc.word = strings.NewReplacer("'", "", `"`, "", `\`, "").Replace(string(text[start:j]))
+	if doc.word != "" {
+		e.docs = append(e.docs, doc)
+	}
+
+	return j - 1
+}
+
+// newline starts the body of the first here-document opened on the line
+// just ended, if any.
+func (e *escaper) newline() {
+	e.inDoc = len(e.docs) > 0
+}
+
+// docLine reads c of a here-document body, ending the body at a line
+// that is its delimiter.
+func (e *escaper) docLine(c byte) {
+	if c != '\n' {
+		e.line = append(e.line, c)
+
+		return
+	}
+
+	line := string(e.line)
+	e.line = e.line[:0]
+
+	if e.docs[0].strip {
+		line = strings.TrimLeft(line, "\t")
+	}
+
+	if line == e.docs[0].word {
+		e.docs = e.docs[1:]
+		e.inDoc = len(e.docs) > 0
+	}
+}
+
+// PwshQuote quotes s as a single PowerShell word. PowerShell treats the
+// typographic single quotes like ', so they are doubled too.
+func PwshQuote(s string) string {
+	var b strings.Builder
+
+	b.WriteByte('\'')
+
+	for _, r := range s {
+		if strings.ContainsRune("'‘’‚‛", r) {
+			b.WriteRune(r)
+		}
+
+		b.WriteRune(r)
+	}
+
+	b.WriteByte('\'')
+
+	return b.String()
+}
+
+// Command is a parsed command action: a command string run by a shell,
+// or args run directly. Parse it once, when the config is loaded.
+type Command struct {
+	shell string
+	cmd   *Template
+	args  []*Template
+}
+
+// ParseCommand parses a command action. With args, each element is a
+// separate template and no shell is involved; otherwise command runs in
+// shell with its template values quoted.
+func ParseCommand(command string, args []string, shell string) (*Command, error) {
+	c := &Command{shell: shell}
+
+	if len(args) == 0 {
+		t, err := ParseCommandTemplate(command, shell)
+		if err != nil {
+			return nil, err
+		}
+
+		c.cmd = t
+
+		return c, nil
+	}
+
+	for _, a := range args {
+		t, err := ParseTemplate(a)
+		if err != nil {
+			return nil, err
+		}
+
+		c.args = append(c.args, t)
+	}
+
+	return c, nil
+}
+
+// Argv renders the command for ev with the config's vars and returns the
+// program and arguments to run.
+func (c *Command) Argv(ev watcher.Event, vars map[string]string) ([]string, error) {
+	data := NewTemplateData(ev)
+	data.Vars = vars
+
+	if c.cmd != nil {
+		script, err := c.cmd.Render(data)
+		if err != nil {
+			return nil, err
+		}
+
+		return shellArgv(c.shell, script), nil
+	}
+
+	argv := make([]string, 0, len(c.args))
+
+	for _, t := range c.args {
+		a, err := t.Render(data)
+		if err != nil {
+			return nil, err
+		}
+
+		argv = append(argv, a)
+	}
+
+	if argv[0] == "" {
+		return nil, errors.New("action: command args render an empty program name")
+	}
+
+	return argv, nil
+}
@@ -0,0 +1,98 @@
+package action
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// Template is a parsed command or log template. It is parsed once, when
+// the config is loaded, and rendered for every event.
+type Template struct {
+	t *template.Template
+}
+
+// ParseTemplate parses src with the template functions available to
+// every action. A var the config does not define renders as "".
+func ParseTemplate(src string) (*Template, error) {
+	t, err := template.New("action").Funcs(funcs).Option("missingkey=zero").Parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Template{t: t}, nil
+}
+
+// Render executes the template with data.
+func (t *Template) Render(data TemplateData) (string, error) {
+	var buf bytes.Buffer
+
+	err := t.t.Execute(&buf, data)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
+
+// funcs are the template functions. As in sprig, the value being
+// transformed comes last so functions chain in pipelines:
+// {{.Name | trimSuffix ".go" | upper}}.
+var funcs = template.FuncMap{
+	"lower":      strings.ToLower,
+	"upper":      strings.ToUpper,
+	"trim":       strings.TrimSpace,
+	"trimPrefix": func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+	"trimSuffix": func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+	"replace":    func(old, repl, s string) string { return strings.ReplaceAll(s, old, repl) },
+	"contains":   func(sub, s string) bool { return strings.Contains(s, sub) },
+	"hasPrefix":  func(prefix, s string) bool { return strings.HasPrefix(s, prefix) },
+	"hasSuffix":  func(suffix, s string) bool { return strings.HasSuffix(s, suffix) },
+	"split":      func(sep, s string) []string { return strings.Split(s, sep) },
+	"join":       func(sep string, elems []string) string { return strings.Join(elems, sep) },
+	"base":       filepath.Base,
+	"dir":        filepath.Dir,
+	"ext":        filepath.Ext,
+	"clean":      filepath.Clean,
+	"toSlash":    filepath.ToSlash,
+	"quote":      strconv.Quote,
+	"shellquote": func(v any) string { return ShellQuote(fmt.Sprint(v)) },
+	"pwshquote":  func(v any) string { return PwshQuote(fmt.Sprint(v)) },
+	"raw":        func(v any) any { return v },
+	"env":        os.Getenv,
+	"default": func(def, v string) string {
+		if v == "" {
+			return def
+		}
+
+		return v
+	},
+}
+
+// ShellQuote quotes s as a single POSIX shell word. Strings made only of
+// characters the shell never interprets are returned unchanged. A leading
+// = is quoted too, since zsh expands =cmd to the path of cmd.
+func ShellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+
+	if strings.IndexFunc(s, needsQuote) < 0 && s[0] != '=' {
+		return s
+	}
+
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func needsQuote(r rune) bool {
+	switch {
+	case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		return false
+	default:
+		return !strings.ContainsRune("@%+=:,./_-", r)
+	}
+}
@@ -0,0 +1,244 @@
+package action
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+func TestTemplateFuncs(t *testing.T) {
+	ev := watcher.Event{Path: "src/Main.go", Type: watcher.Modify, Name: "Main.go", Dir: "src"}
+
+	tests := []struct {
+		tmpl string
+		want string
+	}{
+		{"{{.Ext}} {{.Stem}}", ".go Main"},
+		{"{{.Name | trimSuffix \".go\" | upper}}", "MAIN"},
+		{"{{.Path | replace \"/\" \"_\" | lower}}", "src_main.go"},
+		{"{{if hasSuffix \".go\" .Name}}go{{end}}", "go"},
+		{"{{.Path | split \"/\" | join \",\"}}", "src,Main.go"},
+		{"{{base .Path}} {{dir .Path}} {{ext .Path}}", "Main.go src .go"},
+		{"{{.Vars.env | default \"dev\"}}", "dev"},
+		{"{{.Vars.mode}}", "fast"},
+		{"{{shellquote \"it's here\"}}", `'it'\''s here'`},
+	}
+
+	for _, tt := range tests {
+		data := NewTemplateData(ev)
+		data.Vars = map[string]string{"mode": "fast"}
+
+		got, err := mustTemplate(t, tt.tmpl).Render(data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.tmpl, err)
+
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"":             "''",
+		"main.go":      "main.go",
+		"a/b-c_d=1":    "a/b-c_d=1",
+		"=ls":          "'=ls'",
+		"~root":        "'~root'",
+		"a=~b":         "'a=~b'",
+		"my file.go":   "'my file.go'",
+		"$(rm -rf /)":  "'$(rm -rf /)'",
+		"don't":        `'don'\''t'`,
+		"semi;colon":   "'semi;colon'",
+		"back`tick`":   "'back`tick`'",
+		"new\nline.go": "'new\nline.go'",
+	}
+
+	for in, want := range tests {
+		got := ShellQuote(in)
+		if got != want {
+			t.Errorf("ShellQuote(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestParseCommandTemplate(t *testing.T) {
+	ev := watcher.Event{Path: "my dir/it's.go", Type: watcher.Modify, Name: "it's.go", Dir: "my dir"}
+
+	tests := []struct {
+		shell string
+		tmpl  string
+		want  string
+	}{
+		{"", "cat {{.Path}}", `cat 'my dir/it'\''s.go'`},
+		{"bash", "cd {{.Dir}} && go test {{.Vars.flags | raw}}", "cd 'my dir' && go test -v -race"},
+		{"sh", "echo {{.Path | shellquote}}", `echo 'my dir/it'\''s.go'`},
+		{"sh", "{{$d := .Dir}}{{if .Name}}ls {{$d}}{{end}}", "ls 'my dir'"},
+		{"sh", "stat -c %s {{.Size}} {{.Stem | upper}}", `stat -c %s 0 'IT'\''S'`},
+		{"pwsh", "Get-Item {{.Path}}", "Get-Item 'my dir/it''s.go'"},
+		{"pwsh", "{{.Vars.tool}} build {{.Path}}", "& 'go' build 'my dir/it''s.go'"},
+		{"pwsh", "cd {{.Dir}}; {{.Vars.tool}} vet && {{.Vars.tool | raw}} test", "cd 'my dir'; & 'go' vet && go test"},
+		{"pwsh", "& {{.Vars.tool}} run ({{.Name}})", "& 'go' run ('it''s.go')"},
+		{"sh", "{{.Vars.tool}} build", "go build"},
+	}
+
+	for _, tt := range tests {
+		tmpl, err := ParseCommandTemplate(tt.tmpl, tt.shell)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.tmpl, err)
+		}
+
+		data := NewTemplateData(ev)
+		data.Vars = map[string]string{"flags": "-v -race", "tool": "go"}
+
+		got, err := tmpl.Render(data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.tmpl, err)
+
+			continue
+		}
+
+		if got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.tmpl, got, tt.want)
+		}
+	}
+}
+
+func TestParseCommandTemplateUserQuotes(t *testing.T) {
+	tests := []struct {
+		shell string
+		tmpl  string
+		ok    bool
+	}{
+		{"sh", `echo "{{.Path}}"`, false},
+		{"sh", `echo '{{.Path}}'`, false},
+		{"sh", `echo "it's" {{.Path}}`, true},
+		{"sh", `echo \" {{.Path}} \"`, true},
+		{"sh", `echo "{{.Path | raw}}" {{.Name}}`, true},
+		{"sh", `echo '{{if .Name}}x{{end}}' {{.Name}}`, true},
+		{"bash", `echo "$({{.Vars.tool}})"`, false},
+		{"pwsh", `Write-Output "{{.Path}}"`, false},
+		{"pwsh", "Write-Output `\" {{.Path}}", true},
+		{"pwsh", `Write-Output 'it''s' {{.Path}}`, true},
+		{"sh", "echo # it's {{.Path}}", true},
+		{"sh", "echo hi # it's\necho {{.Path}}", true},
+		{"sh", "echo it#s {{.Path}}", true},
+		{"sh", "echo it#s' {{.Path}}'", false},
+		{"pwsh", "Write-Output 1 # it's {{.Path}}", true},
+		{"sh", "cat <<EOF\n{{.Path}}\nEOF", false},
+		{"bash", "cat <<-'EOF'\n\t{{.Path}}\n\tEOF", false},
+		{"sh", "cat <<EOF\nit's\nEOF\necho {{.Path}}", true},
+		{"sh", "cat <<A <<B\na\nA\n{{.Path}}\nB", false},
+		{"sh", "cat <<EOF {{.Path}}\nbody\nEOF", true},
+		{"bash", "cat <<< {{.Path}}", true},
+		{"sh", "echo {{.Path}} <<EOF\nEOF", true},
+	}
+
+	for _, tt := range tests {
+		_, err := ParseCommandTemplate(tt.tmpl, tt.shell)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s %s: err = %v, want ok = %v", tt.shell, tt.tmpl, err, tt.ok)
+		}
+	}
+}
+
+func TestParseCommandTemplateComment(t *testing.T) {
+	tmpl, err := ParseCommandTemplate("echo {{.Name}} # {{.Path}}", "sh")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := tmpl.Render(NewTemplateData(watcher.Event{Path: "a\nrm -rf ~ #", Name: "a"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A line break in the value would end the comment and run the rest.
+	if want := "echo a # a rm -rf ~ #"; got != want {
+		t.Errorf("rendered %q, want %q", got, want)
+	}
+}
+
+func TestPwshQuote(t *testing.T) {
+	if got := PwshQuote("a ‘b’ 'c' $d"); got != "'a ‘‘b’’ ''c'' $d'" {
+		t.Errorf("PwshQuote = %q", got)
+	}
+}
+
+func TestTemplateDataFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "notes.txt")
+
+	err := os.WriteFile(path, []byte("hello"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d := NewTemplateData(watcher.Event{Path: path, Type: watcher.Create, Name: "notes.txt", Dir: dir})
+
+	if d.Size != 5 || d.ModTime == "" || d.Mode != "-rw-------" {
+		t.Errorf("size = %d, modtime = %q, mode = %q", d.Size, d.ModTime, d.Mode)
+	}
+
+	if !filepath.IsAbs(d.Abs) {
+		t.Errorf("abs = %q, want an absolute path", d.Abs)
+	}
+
+	want := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if got := d.SHA256(); got != want {
+		t.Errorf("sha256 = %q, want %q", got, want)
+	}
+
+	gone := NewTemplateData(watcher.Event{Path: filepath.Join(dir, "gone"), Type: watcher.Delete, Name: "gone", Dir: dir})
+	if gone.Size != 0 || gone.ModTime != "" || gone.SHA256() != "" {
+		t.Errorf("deleted file should have no size, modtime or hash: %+v", gone)
+	}
+}
+
+func TestTemplateDataGit(t *testing.T) {
+	root := t.TempDir()
+	gitDir := filepath.Join(root, ".git")
+	sub := filepath.Join(root, "pkg")
+	commit := "0123456789abcdef0123456789abcdef01234567"
+
+	for _, d := range []string{filepath.Join(gitDir, "refs", "heads"), sub} {
+		err := os.MkdirAll(d, 0o750)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write := func(name, content string) {
+		t.Helper()
+
+		err := os.WriteFile(filepath.Join(gitDir, name), []byte(content), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("HEAD", "ref: refs/heads/main\n")
+	write("packed-refs", "# pack-refs with: peeled\n"+commit+" refs/heads/main\n")
+
+	d := NewTemplateData(watcher.Event{Path: filepath.Join(sub, "a.go"), Name: "a.go", Dir: sub})
+
+	if d.GitRoot() != root || d.GitBranch() != "main" || d.GitCommit() != commit {
+		t.Errorf("root = %q, branch = %q, commit = %q", d.GitRoot(), d.GitBranch(), d.GitCommit())
+	}
+
+	write("HEAD", commit+"\n")
+
+	if d.GitBranch() != "" || d.GitCommit() != commit {
+		t.Errorf("detached: branch = %q, commit = %q", d.GitBranch(), d.GitCommit())
+	}
+
+	outside := NewTemplateData(watcher.Event{Path: "/a.go", Name: "a.go", Dir: "/"})
+	if outside.GitRoot() != "" || outside.GitCommit() != "" {
+		t.Error("expected no repository at /")
+	}
+}
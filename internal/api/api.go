@@ -0,0 +1,188 @@
+// Package api implements a local HTTP control API for querying rule state,
+// triggering and pausing rules, streaming events and reloading config.
+package api
+
+import (
+	"time"
+
+	"github.com/devaloi/watchdog/internal/action"
+	"github.com/devaloi/watchdog/internal/config"
+	"github.com/devaloi/watchdog/internal/display"
+	"github.com/devaloi/watchdog/internal/rulestate"
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+// Controller performs the actions requested through the API.
+type Controller interface {
+	// Trigger runs the rule's action now, bypassing debounce.
+	Trigger(rule string)
+	// SetPaused stops or resumes reacting to events for the rule.
+	SetPaused(rule string, paused bool)
+	// Reload re-reads the config file and replaces the running rules.
+	// It reports the new config through Banner.
+	Reload() error
+}
+
+// RuleStatus is the state of one rule as returned by GET /rules.
+type RuleStatus struct {
+	Name     string          `json:"name"`
+	Action   string          `json:"action"`
+	State    rulestate.State `json:"state"`
+	Paused   bool            `json:"paused"`
+	LastRun  string          `json:"last_run,omitempty"`
+	OK       *bool           `json:"ok,omitempty"`
+	ExitCode *int            `json:"exit_code,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// Server tracks rule state through the display.Printer lifecycle and serves
+// it over HTTP. Every Printer call is also broadcast to /events as a
+// display.Record. It is safe for concurrent use.
+type Server struct {
+	ctl    Controller
+	token  string
+	now    func() time.Time
+	state  *rulestate.Tracker
+	events *broadcaster
+	json   *display.JSONOutput
+}
+
+var _ display.Printer = (*Server)(nil)
+
+// Option configures a Server.
+type Option func(*Server)
+
+// Token requires every request to carry "Authorization: Bearer <token>".
+// An empty token leaves the API open to local clients.
+func Token(token string) Option {
+	return func(s *Server) {
+		s.token = token
+	}
+}
+
+// New creates a Server that sends requested actions to ctl.
+func New(ctl Controller, opts ...Option) *Server {
+	events := newBroadcaster()
+
+	s := &Server{
+		ctl:    ctl,
+		now:    time.Now,
+		events: events,
+		json:   display.NewJSONOutput(events),
+	}
+
+	s.state = rulestate.New(func() time.Time { return s.now() })
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+// Rules returns a snapshot of every rule's status in config order.
+func (s *Server) Rules() []RuleStatus {
+	rules := s.state.Rules()
+
+	out := make([]RuleStatus, 0, len(rules))
+	for _, st := range rules {
+		out = append(out, ruleStatus(st))
+	}
+
+	return out
+}
+
+// Rule returns the status of the named rule.
+func (s *Server) Rule(name string) (RuleStatus, bool) {
+	st, ok := s.state.Rule(name)
+	if !ok {
+		return RuleStatus{}, false
+	}
+
+	return ruleStatus(st), true
+}
+
+// Banner registers the configured rules. On reload, rules that still
+// exist keep their state and removed rules are dropped.
+func (s *Server) Banner(cfg *config.Config, configPath string) {
+	s.state.Banner(cfg, configPath)
+	s.json.Banner(cfg, configPath)
+}
+
+// Event marks a matched rule as debouncing.
+func (s *Server) Event(ev watcher.Event, ruleName string) {
+	s.state.Event(ev, ruleName)
+	s.json.Event(ev, ruleName)
+}
+
+// Verbose forwards a filtered event to /events.
+func (s *Server) Verbose(ev watcher.Event, reason string) {
+	s.json.Verbose(ev, reason)
+}
+
+// ActionStart marks the rule as running.
+func (s *Server) ActionStart(ruleName, actionType string, ev watcher.Event) {
+	s.state.ActionStart(ruleName, actionType, ev)
+	s.json.ActionStart(ruleName, actionType, ev)
+}
+
+// ActionResult records the result of an action.
+func (s *Server) ActionResult(ruleName string, err error, elapsed time.Duration) {
+	s.state.ActionResult(ruleName, err, elapsed)
+	s.json.ActionResult(ruleName, err, elapsed)
+}
+
+// CommandExit records the exit code of a finished command.
+func (s *Server) CommandExit(ruleName string, res action.Result) {
+	s.state.CommandExit(ruleName, res)
+	s.json.CommandExit(ruleName, res)
+}
+
+// DryRun returns the rule to idle.
+func (s *Server) DryRun(ruleName, actionType string) {
+	s.state.DryRun(ruleName, actionType)
+	s.json.DryRun(ruleName, actionType)
+}
+
+// Shutdown forwards the shutdown record and ends every /events stream.
+func (s *Server) Shutdown() {
+	s.json.Shutdown()
+	s.events.close()
+}
+
+// setPaused records the paused flag and returns the updated status.
+func (s *Server) setPaused(name string, paused bool) (RuleStatus, bool) {
+	st, ok := s.state.SetPaused(name, paused)
+	if !ok {
+		return RuleStatus{}, false
+	}
+
+	return ruleStatus(st), true
+}
+
+// ruleStatus converts a tracked status to its JSON form.
+func ruleStatus(st rulestate.Status) RuleStatus {
+	rs := RuleStatus{
+		Name:   st.Name,
+		Action: st.Action,
+		State:  st.State,
+		Paused: st.Paused,
+		Error:  st.Error,
+	}
+
+	if !st.LastRun.IsZero() {
+		rs.LastRun = st.LastRun.UTC().Format(time.RFC3339)
+	}
+
+	if st.Finished {
+		ok := st.OK
+		rs.OK = &ok
+	}
+
+	if st.HasExit {
+		code := st.ExitCode
+		rs.ExitCode = &code
+	}
+
+	return rs
+}
@@ -0,0 +1,364 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/action"
+	"github.com/devaloi/watchdog/internal/config"
+	"github.com/devaloi/watchdog/internal/display"
+	"github.com/devaloi/watchdog/internal/rulestate"
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+type fakeController struct {
+	mu        sync.Mutex
+	calls     []string
+	reloadErr error
+}
+
+func (f *fakeController) Trigger(rule string) { f.record("trigger " + rule) }
+
+func (f *fakeController) SetPaused(rule string, paused bool) {
+	f.record(fmt.Sprintf("paused %s %v", rule, paused))
+}
+
+func (f *fakeController) Reload() error {
+	f.record("reload")
+
+	return f.reloadErr
+}
+
+func (f *fakeController) record(call string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.calls = append(f.calls, call)
+}
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Rules: []config.Rule{
+			{Name: "build", Action: config.Action{Type: "command"}},
+			{Name: "notify", Action: config.Action{Type: "webhook"}},
+		},
+	}
+}
+
+func testServer() (*Server, *fakeController) {
+	ctl := &fakeController{}
+	s := New(ctl)
+	s.now = func() time.Time { return time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC) }
+	s.Banner(testConfig(), "watchdog.yaml")
+
+	return s, ctl
+}
+
+func do(t *testing.T, h http.Handler, method, path string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, nil)
+	req.Host = "127.0.0.1:9000"
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestRuleStatus(t *testing.T) {
+	s, _ := testServer()
+	ev := watcher.Event{Path: "main.go", Type: watcher.Modify}
+
+	s.Event(ev, "build")
+	s.ActionStart("build", "command", ev)
+	s.ActionResult("build", nil, time.Millisecond)
+
+	rs, _ := s.Rule("build")
+	if rs.State != rulestate.Running || rs.LastRun != "2025-01-01T12:00:00Z" {
+		t.Errorf("running command status = %+v", rs)
+	}
+
+	s.CommandExit("build", action.Result{ExitCode: 1})
+
+	rs, _ = s.Rule("build")
+	if rs.State != rulestate.Failed || rs.OK == nil || *rs.OK || rs.ExitCode == nil || *rs.ExitCode != 1 {
+		t.Errorf("failed command status = %+v", rs)
+	}
+
+	s.ActionStart("notify", "webhook", ev)
+	s.ActionResult("notify", errors.New("refused"), time.Millisecond)
+
+	rs, _ = s.Rule("notify")
+	if rs.State != rulestate.Failed || rs.Error != "refused" {
+		t.Errorf("failed webhook status = %+v", rs)
+	}
+
+	s.ActionResult("notify", nil, time.Millisecond)
+
+	rs, _ = s.Rule("notify")
+	if rs.State != rulestate.Idle || rs.OK == nil || !*rs.OK || rs.Error != "" {
+		t.Errorf("passing webhook status = %+v", rs)
+	}
+}
+
+func TestGetRules(t *testing.T) {
+	s, _ := testServer()
+
+	rec := do(t, s.Handler(), http.MethodGet, "/rules")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+
+	var rules []RuleStatus
+
+	err := json.Unmarshal(rec.Body.Bytes(), &rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rules) != 2 || rules[0].Name != "build" || rules[0].State != rulestate.Idle || rules[1].Action != "webhook" {
+		t.Errorf("rules = %+v", rules)
+	}
+
+	rec = do(t, s.Handler(), http.MethodGet, "/rules/missing")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("unknown rule status = %d, want 404", rec.Code)
+	}
+}
+
+func TestTriggerAndPause(t *testing.T) {
+	s, ctl := testServer()
+	h := s.Handler()
+
+	if rec := do(t, h, http.MethodPost, "/rules/build/trigger"); rec.Code != http.StatusAccepted {
+		t.Errorf("trigger status = %d", rec.Code)
+	}
+
+	if rec := do(t, h, http.MethodPost, "/rules/build/pause"); rec.Code != http.StatusOK {
+		t.Errorf("pause status = %d", rec.Code)
+	}
+
+	if rs, _ := s.Rule("build"); !rs.Paused {
+		t.Error("expected build to be paused")
+	}
+
+	do(t, h, http.MethodPost, "/rules/build/resume")
+
+	if rs, _ := s.Rule("build"); rs.Paused {
+		t.Error("expected build to be resumed")
+	}
+
+	if rec := do(t, h, http.MethodPost, "/rules/missing/trigger"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown trigger status = %d, want 404", rec.Code)
+	}
+
+	if rec := do(t, h, http.MethodGet, "/rules/build/trigger"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("GET trigger status = %d, want 405", rec.Code)
+	}
+
+	want := []string{"trigger build", "paused build true", "paused build false"}
+	if strings.Join(ctl.calls, ";") != strings.Join(want, ";") {
+		t.Errorf("calls = %v, want %v", ctl.calls, want)
+	}
+}
+
+func TestReload(t *testing.T) {
+	s, ctl := testServer()
+
+	s.ActionStart("build", "command", watcher.Event{})
+
+	if rec := do(t, s.Handler(), http.MethodPost, "/reload"); rec.Code != http.StatusOK {
+		t.Errorf("reload status = %d", rec.Code)
+	}
+
+	// The controller reports the new config through Banner.
+	s.Banner(&config.Config{Rules: []config.Rule{
+		{Name: "build", Action: config.Action{Type: "command"}},
+		{Name: "lint", Action: config.Action{Type: "command"}},
+	}}, "watchdog.yaml")
+
+	rules := s.Rules()
+	if len(rules) != 2 || rules[0].State != rulestate.Running || rules[1].Name != "lint" {
+		t.Errorf("rules after reload = %+v", rules)
+	}
+
+	ctl.reloadErr = errors.New("config: at least one rule is required")
+
+	rec := do(t, s.Handler(), http.MethodPost, "/reload")
+	if rec.Code != http.StatusUnprocessableEntity || !strings.Contains(rec.Body.String(), "at least one rule") {
+		t.Errorf("failed reload = %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestEventStream(t *testing.T) {
+	s, _ := testServer()
+
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/events") //nolint:noctx // test request
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = resp.Body.Close() }()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	// The handler subscribes before writing headers, so events sent now
+	// are delivered.
+	s.Event(watcher.Event{Path: "main.go", Type: watcher.Modify}, "build")
+
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, ok := strings.CutPrefix(strings.TrimSpace(line), "data: ")
+	if !ok {
+		t.Fatalf("unexpected line %q", line)
+	}
+
+	var rec display.Record
+
+	err = json.Unmarshal([]byte(data), &rec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.Type != display.RecordMatch || rec.Rule != "build" || rec.Path != "main.go" {
+		t.Errorf("record = %+v", rec)
+	}
+
+	s.Shutdown()
+}
+
+func TestServeUnixSocket(t *testing.T) {
+	s, _ := testServer()
+	sock := filepath.Join(t.TempDir(), "watchdog.sock")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() { done <- s.Serve(ctx, UnixPrefix+sock) }()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+
+	var (
+		resp *http.Response
+		err  error
+	)
+
+	for range 50 {
+		resp, err = client.Get("http://watchdog/rules") //nolint:noctx // test request
+		if err == nil {
+			break
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("status = %d", resp.StatusCode)
+	}
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve returned %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve did not return after cancel")
+	}
+}
+
+func TestHandlerRejectsForeignRequests(t *testing.T) {
+	s, ctl := testServer()
+	h := s.Handler()
+
+	tests := []struct {
+		name   string
+		host   string
+		origin string
+		want   int
+	}{
+		{"loopback", "127.0.0.1:9000", "", http.StatusAccepted},
+		{"localhost", "localhost:9000", "http://localhost:9000", http.StatusAccepted},
+		{"ipv6 loopback", "[::1]:9000", "", http.StatusAccepted},
+		{"rebound host", "evil.example:9000", "", http.StatusForbidden},
+		{"lan address", "192.168.1.5:9000", "", http.StatusForbidden},
+		{"cross origin", "127.0.0.1:9000", "https://evil.example", http.StatusForbidden},
+		{"other local port", "127.0.0.1:9000", "http://127.0.0.1:3000", http.StatusForbidden},
+		{"opaque origin", "127.0.0.1:9000", "null", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/rules/build/trigger", nil)
+		req.Host = tt.host
+
+		if tt.origin != "" {
+			req.Header.Set("Origin", tt.origin)
+		}
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, rec.Code, tt.want)
+		}
+	}
+
+	if len(ctl.calls) != 3 {
+		t.Errorf("calls = %v, want only the allowed triggers", ctl.calls)
+	}
+}
+
+func TestHandlerToken(t *testing.T) {
+	s := New(&fakeController{}, Token("s3cret"))
+	s.Banner(testConfig(), "watchdog.yaml")
+	h := s.Handler()
+
+	for auth, want := range map[string]int{
+		"":              http.StatusUnauthorized,
+		"Bearer wrong":  http.StatusUnauthorized,
+		"s3cret":        http.StatusUnauthorized,
+		"Bearer s3cret": http.StatusOK,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/rules", nil)
+		req.Host = "127.0.0.1:9000"
+		req.Header.Set("Authorization", auth)
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		if rec.Code != want {
+			t.Errorf("Authorization %q: status = %d, want %d", auth, rec.Code, want)
+		}
+	}
+}
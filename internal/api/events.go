@@ -0,0 +1,70 @@
+package api
+
+import (
+	"bytes"
+	"sync"
+)
+
+// subscriberBuffer is how many records a slow /events client may fall
+// behind before records are dropped for it.
+const subscriberBuffer = 64
+
+// broadcaster fans out each written JSON line to every subscriber.
+// display.JSONOutput writes exactly one record per Write call.
+type broadcaster struct {
+	mu     sync.Mutex
+	subs   map[chan []byte]struct{}
+	done   chan struct{}
+	closed bool
+}
+
+func newBroadcaster() *broadcaster {
+	return &broadcaster{
+		subs: make(map[chan []byte]struct{}),
+		done: make(chan struct{}),
+	}
+}
+
+func (b *broadcaster) Write(p []byte) (int, error) {
+	line := bytes.TrimRight(p, "\n")
+	msg := append([]byte(nil), line...)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs {
+		select {
+		case ch <- msg:
+		default:
+		}
+	}
+
+	return len(p), nil
+}
+
+// subscribe registers a new subscriber. The returned function must be
+// called to unsubscribe.
+func (b *broadcaster) subscribe() (<-chan []byte, func()) {
+	ch := make(chan []byte, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subs, ch)
+		b.mu.Unlock()
+	}
+}
+
+// close ends every stream; subscribers see done closed.
+func (b *broadcaster) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.closed {
+		b.closed = true
+		close(b.done)
+	}
+}
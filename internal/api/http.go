@@ -0,0 +1,258 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// UnixPrefix marks an address as a Unix socket path, e.g. "unix:/tmp/watchdog.sock".
+const UnixPrefix = "unix:"
+
+const (
+	shutdownTimeout   = 5 * time.Second
+	readHeaderTimeout = 5 * time.Second
+	keepAliveInterval = 15 * time.Second
+)
+
+// Handler returns the API's http.Handler. Requests over TCP must name a
+// loopback host, so a DNS rebinding page cannot reach the API, and a
+// request with an Origin header must come from the API's own origin, so
+// other web pages cannot post to it. With a token configured, every
+// request must also carry it.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /rules", s.handleRules)
+	mux.HandleFunc("GET /rules/{name}", s.handleRule)
+	mux.HandleFunc("POST /rules/{name}/trigger", s.handleTrigger)
+	mux.HandleFunc("POST /rules/{name}/pause", s.handlePause(true))
+	mux.HandleFunc("POST /rules/{name}/resume", s.handlePause(false))
+	mux.HandleFunc("GET /events", s.handleEvents)
+	mux.HandleFunc("POST /reload", s.handleReload)
+
+	return s.guard(mux)
+}
+
+func (s *Server) guard(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !viaUnixSocket(r) && !isLoopbackHost(r.Host) {
+			writeError(w, http.StatusForbidden, "host not allowed: "+r.Host)
+
+			return
+		}
+
+		if origin := r.Header.Get("Origin"); origin != "" && !sameOrigin(origin, r.Host) {
+			writeError(w, http.StatusForbidden, "cross-origin request rejected: "+origin)
+
+			return
+		}
+
+		if s.token != "" && !s.authorized(r) {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "missing or invalid token")
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+func viaUnixSocket(r *http.Request) bool {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+
+	return ok && addr.Network() == "unix"
+}
+
+func isLoopbackHost(hostport string) bool {
+	host, _, err := net.SplitHostPort(hostport)
+	if err != nil {
+		host = strings.Trim(hostport, "[]")
+	}
+
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+func sameOrigin(origin, host string) bool {
+	u, err := url.Parse(origin)
+
+	return err == nil && u.Scheme == "http" && u.Host == host
+}
+
+// Serve serves the API on addr until ctx is canceled. addr is a TCP
+// address, or a Unix socket path prefixed with "unix:". A stale socket
+// file left by a previous run is replaced.
+func (s *Server) Serve(ctx context.Context, addr string) error {
+	network := "tcp"
+
+	if path, ok := strings.CutPrefix(addr, UnixPrefix); ok {
+		network, addr = "unix", path
+
+		info, err := os.Lstat(path)
+		if err == nil && info.Mode().Type() == fs.ModeSocket {
+			_ = os.Remove(path)
+		}
+	}
+
+	ln, err := (&net.ListenConfig{}).Listen(ctx, network, addr)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Handler: s.Handler(), ReadHeaderTimeout: readHeaderTimeout}
+
+	go func() {
+		<-ctx.Done()
+
+		// End /events streams first; Shutdown waits for active requests.
+		s.events.close()
+
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		defer cancel()
+
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	err = srv.Serve(ln)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+func (s *Server) handleRules(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.Rules())
+}
+
+func (s *Server) handleRule(w http.ResponseWriter, r *http.Request) {
+	rs, ok := s.Rule(r.PathValue("name"))
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown rule: "+r.PathValue("name"))
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rs)
+}
+
+func (s *Server) handleTrigger(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+
+	rs, ok := s.Rule(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown rule: "+name)
+
+		return
+	}
+
+	s.ctl.Trigger(name)
+
+	writeJSON(w, http.StatusAccepted, rs)
+}
+
+func (s *Server) handlePause(paused bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := r.PathValue("name")
+
+		rs, ok := s.setPaused(name, paused)
+		if !ok {
+			writeError(w, http.StatusNotFound, "unknown rule: "+name)
+
+			return
+		}
+
+		s.ctl.SetPaused(name, paused)
+
+		writeJSON(w, http.StatusOK, rs)
+	}
+}
+
+func (s *Server) handleReload(w http.ResponseWriter, _ *http.Request) {
+	err := s.ctl.Reload()
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.Rules())
+}
+
+// handleEvents streams display.Records as Server-Sent Events. Each record
+// is one "data:" line; comments are sent periodically as keep-alives.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, "streaming unsupported")
+
+		return
+	}
+
+	records, unsubscribe := s.events.subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-s.events.done:
+			return
+		case <-keepAlive.C:
+			_, err := w.Write([]byte(": keep-alive\n\n"))
+			if err != nil {
+				return
+			}
+		case rec := <-records:
+			_, err := w.Write([]byte("data: " + string(rec) + "\n\n"))
+			if err != nil {
+				return
+			}
+		}
+
+		flusher.Flush()
+	}
+}
+
+type errorBody struct {
+	Error string `json:"error"`
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, errorBody{Error: msg})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -3,43 +3,163 @@ package config
 
 import (
 	"errors"
+	"net"
 	"os"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/devaloi/watchdog/internal/action"
+	"github.com/devaloi/watchdog/internal/matcher"
+	"github.com/devaloi/watchdog/internal/watcher"
 	"gopkg.in/yaml.v3"
 )
 
-// Config is the top-level watchdog configuration.
+// Config is the top-level watchdog configuration. Vars are user-defined
+// values available to command and log templates as .Vars.
 type Config struct {
-	Global Global `yaml:"global"`
-	Rules  []Rule `yaml:"rules"`
+	Global Global            `yaml:"global"`
+	Vars   map[string]string `yaml:"vars"`
+	Rules  []Rule            `yaml:"rules"`
 }
 
 // Global holds default settings applied to all rules.
+// CaseInsensitive makes every watch and ignore pattern ignore case.
+// FollowSymlinks watches the targets of symlinked directories. Shell runs
+// command strings; rules may override it, and args actions ignore it.
 type Global struct {
-	Debounce Duration `yaml:"debounce"`
-	Ignore   []string `yaml:"ignore"`
+	Debounce        Duration      `yaml:"debounce"`
+	Shell           string        `yaml:"shell"`
+	Ignore          []string      `yaml:"ignore"`
+	CaseInsensitive bool          `yaml:"case_insensitive"`
+	FollowSymlinks  bool          `yaml:"follow_symlinks"`
+	EditorSaves     EditorSaves   `yaml:"editor_saves"`
+	CommandOutput   CommandOutput `yaml:"command_output"`
+	Display         Display       `yaml:"display"`
+	MetricsAddr     string        `yaml:"metrics_addr"`
+	APIAddr         string        `yaml:"api_addr"`
+	APIToken        string        `yaml:"api_token"`
+	Journal         string        `yaml:"journal"`
 }
 
+// Display controls terminal styling. Color is "auto", "always" or
+// "never"; Theme maps a role such as "success" to color names.
+type Display struct {
+	Color string            `yaml:"color"`
+	ASCII bool              `yaml:"ascii"`
+	Theme map[string]string `yaml:"theme"`
+}
+
+// ThemeRoles are the roles display.theme may color.
+var ThemeRoles = []string{
+	"create", "modify", "delete", "rename", "chmod", "close_write",
+	"success", "failure", "warning", "accent", "muted", "title",
+}
+
+// ThemeColors are the names a display.theme value is built from.
+var ThemeColors = []string{
+	"none", "bold", "dim", "italic", "underline",
+	"black", "red", "green", "yellow", "blue", "magenta", "cyan", "white", "gray",
+	"bright-red", "bright-green", "bright-yellow", "bright-blue", "bright-magenta", "bright-cyan", "bright-white",
+}
+
+// CommandOutput controls how command action output is attributed and captured.
+type CommandOutput struct {
+	Prefix *bool  `yaml:"prefix"`
+	Stderr bool   `yaml:"stderr"`
+	LogDir string `yaml:"log_dir"`
+}
+
+// PrefixEnabled reports whether output lines get a [rule] prefix (default true).
+func (c CommandOutput) PrefixEnabled() bool {
+	return c.Prefix == nil || *c.Prefix
+}
+
+// EditorSaves controls how saves through a temporary file are reported.
+// TempPatterns are added to the watcher's built-in editor patterns.
+type EditorSaves struct {
+	Collapse     *bool    `yaml:"collapse"`
+	TempPatterns []string `yaml:"temp_patterns"`
+}
+
+// CollapseEnabled reports whether save sequences collapse into a single
+// modify (default true).
+func (e EditorSaves) CollapseEnabled() bool {
+	return e.Collapse == nil || *e.Collapse
+}
+
+// DefaultStableTimeout is how long a stable_for wait lasts when the rule
+// sets no stable_timeout.
+const DefaultStableTimeout = 5 * time.Minute
+
 // Rule defines a single watch rule with patterns, event filters, and an action.
+// Contains and ContentRegex optionally restrict matches by the leading bytes
+// of the changed file. StableFor delays the action until the file has not
+// changed for that long, giving up after StableTimeout.
 type Rule struct {
-	Name     string   `yaml:"name"`
-	Watch    []string `yaml:"watch"`
-	Events   []string `yaml:"events"`
-	Debounce Duration `yaml:"debounce"`
-	Action   Action   `yaml:"action"`
+	Name          string   `yaml:"name"`
+	Watch         []string `yaml:"watch"`
+	Events        []string `yaml:"events"`
+	Contains      string   `yaml:"contains"`
+	ContentRegex  string   `yaml:"content_regex"`
+	Debounce      Duration `yaml:"debounce"`
+	StableFor     Duration `yaml:"stable_for"`
+	StableTimeout Duration `yaml:"stable_timeout"`
+	Action        Action   `yaml:"action"`
+
+	// The content predicate parsed by Parse while validating.
+	content *matcher.ContentPredicate
 }
 
-// Action describes what to do when a rule matches.
+// ParsedContent returns the rule's content predicate, or nil if it sets
+// neither Contains nor ContentRegex. Parse keeps the predicate it
+// validated; a rule built in code is compiled now.
+func (r Rule) ParsedContent() (*matcher.ContentPredicate, error) {
+	if r.content != nil || (r.Contains == "" && r.ContentRegex == "") {
+		return r.content, nil
+	}
+
+	return matcher.NewContentPredicate(r.Contains, r.ContentRegex)
+}
+
+// StableWait returns the longest time to wait for a file to become
+// stable, defaulting to DefaultStableTimeout.
+func (r Rule) StableWait() time.Duration {
+	if r.StableTimeout.Duration > 0 {
+		return r.StableTimeout.Duration
+	}
+
+	return DefaultStableTimeout
+}
+
+// Action describes what to do when a rule matches. A command action sets
+// either Command, run by a shell, or Args, run directly.
 type Action struct {
 	Type    string            `yaml:"type"`
 	Command string            `yaml:"command"`
+	Args    []string          `yaml:"args"`
+	Shell   string            `yaml:"shell"`
 	Dir     string            `yaml:"dir"`
 	URL     string            `yaml:"url"`
 	Method  string            `yaml:"method"`
 	Headers map[string]string `yaml:"headers"`
 	Timeout Duration          `yaml:"timeout"`
 	Format  string            `yaml:"format"`
+	Addr    string            `yaml:"addr"`
+
+	// Templates parsed by Parse while validating.
+	command *action.Command
+	format  *action.Template
+}
+
+// ParsedFormat returns a log action's parsed format. Parse keeps the
+// parse it validated; an action built in code is parsed now.
+func (a Action) ParsedFormat() (*action.Template, error) {
+	if a.format != nil {
+		return a.format, nil
+	}
+
+	return action.ParseTemplate(a.Format)
 }
 
 // Duration wraps time.Duration for YAML unmarshalling.
@@ -91,9 +211,40 @@ func Parse(data []byte) (*Config, error) {
 	return &cfg, nil
 }
 
+// ListsEvent reports whether any rule lists the event type in its events,
+// such as close_write, which the watcher only reports when asked to.
+func (c *Config) ListsEvent(name string) bool {
+	for _, r := range c.Rules {
+		if slices.Contains(r.Events, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Shell returns the shell that runs a's command string.
+func (c *Config) Shell(a Action) string {
+	if a.Shell != "" {
+		return a.Shell
+	}
+
+	return c.Global.Shell
+}
+
+// ParsedCommand returns a command action's command, parsed for the shell
+// it runs in, like ParsedFormat.
+func (c *Config) ParsedCommand(a Action) (*action.Command, error) {
+	if a.command != nil {
+		return a.command, nil
+	}
+
+	return action.ParseCommand(a.Command, a.Args, c.Shell(a))
+}
+
 func isValidActionType(t string) bool {
 	switch t {
-	case "command", "webhook", "log":
+	case "command", "webhook", "log", "livereload":
 		return true
 	default:
 		return false
@@ -105,20 +256,88 @@ func validate(cfg *Config) error {
 		return errors.New("config: at least one rule is required")
 	}
 
+	switch cfg.Global.Display.Color {
+	case "", "auto", "always", "never":
+	default:
+		return errors.New("config: invalid display color " + cfg.Global.Display.Color + ": must be auto, always or never")
+	}
+
+	err := validateTheme(cfg.Global.Display.Theme)
+	if err != nil {
+		return err
+	}
+
+	if cfg.Global.MetricsAddr != "" {
+		_, _, err := net.SplitHostPort(cfg.Global.MetricsAddr)
+		if err != nil {
+			return errors.New("config: invalid metrics_addr " + cfg.Global.MetricsAddr + ": " + err.Error())
+		}
+	}
+
+	err = validateAPIAddr(cfg.Global.APIAddr)
+	if err != nil {
+		return err
+	}
+
+	if !isValidShell(cfg.Global.Shell) {
+		return errors.New("config: invalid shell " + cfg.Global.Shell + ": must be " + strings.Join(action.Shells, ", "))
+	}
+
+	for _, pattern := range cfg.Global.Ignore {
+		err := matcher.ValidatePattern(pattern)
+		if err != nil {
+			return errors.New("config: invalid ignore pattern " + pattern + ": " + err.Error())
+		}
+	}
+
+	for _, pattern := range cfg.Global.EditorSaves.TempPatterns {
+		err := matcher.ValidatePattern(pattern)
+		if err != nil {
+			return errors.New("config: invalid editor_saves temp pattern " + pattern + ": " + err.Error())
+		}
+	}
+
+	names := make(map[string]bool, len(cfg.Rules))
+
 	for i, r := range cfg.Rules {
 		if r.Name == "" {
 			return errors.New("config: rule at index " + itoa(i) + " is missing a name")
 		}
 
+		// Rules are looked up by name for their settings, the control API
+		// and log files.
+		if names[r.Name] {
+			return errors.New("config: duplicate rule name " + r.Name)
+		}
+
+		names[r.Name] = true
+
 		if len(r.Watch) == 0 {
 			return errors.New("config: rule " + r.Name + " must have at least one watch pattern")
 		}
 
+		err := validatePatterns(&cfg.Rules[i])
+		if err != nil {
+			return err
+		}
+
+		err = validateStable(r)
+		if err != nil {
+			return err
+		}
+
+		for _, e := range r.Events {
+			_, err := watcher.ParseEventType(e)
+			if err != nil {
+				return errors.New("config: rule " + r.Name + " has invalid event " + e)
+			}
+		}
+
 		if !isValidActionType(r.Action.Type) {
 			return errors.New("config: rule " + r.Name + " has invalid action type: " + r.Action.Type)
 		}
 
-		err := validateAction(r)
+		err = validateAction(cfg, &cfg.Rules[i])
 		if err != nil {
 			return err
 		}
@@ -127,12 +346,97 @@ func validate(cfg *Config) error {
 	return nil
 }
 
-func validateAction(r Rule) error {
+func validateTheme(theme map[string]string) error {
+	roles := make([]string, 0, len(theme))
+	for role := range theme {
+		roles = append(roles, role)
+	}
+
+	slices.Sort(roles)
+
+	for _, role := range roles {
+		if !slices.Contains(ThemeRoles, role) {
+			return errors.New("config: unknown display theme role " + role + ": must be " + strings.Join(ThemeRoles, ", "))
+		}
+
+		for _, name := range strings.Fields(theme[role]) {
+			if !slices.Contains(ThemeColors, name) {
+				return errors.New("config: unknown color " + name + " for display theme role " + role)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateAPIAddr accepts a TCP host:port or a "unix:" socket path.
+func validateAPIAddr(addr string) error {
+	if addr == "" {
+		return nil
+	}
+
+	if path, ok := strings.CutPrefix(addr, "unix:"); ok {
+		if path == "" {
+			return errors.New("config: api_addr unix: requires a socket path")
+		}
+
+		return nil
+	}
+
+	_, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return errors.New("config: invalid api_addr " + addr + ": " + err.Error())
+	}
+
+	return nil
+}
+
+// validatePatterns checks r's patterns and keeps its content predicate.
+func validatePatterns(r *Rule) error {
+	positive := false
+
+	for _, pattern := range r.Watch {
+		err := matcher.ValidatePattern(pattern)
+		if err != nil {
+			return errors.New("config: rule " + r.Name + " has invalid pattern " + pattern + ": " + err.Error())
+		}
+
+		if !matcher.IsNegated(pattern) {
+			positive = true
+		}
+	}
+
+	if !positive {
+		return errors.New("config: rule " + r.Name + " must have at least one non-negated watch pattern")
+	}
+
+	content, err := r.ParsedContent()
+	if err != nil {
+		return errors.New("config: rule " + r.Name + " has invalid content_regex: " + err.Error())
+	}
+
+	r.content = content
+
+	return nil
+}
+
+func validateStable(r Rule) error {
+	if r.StableFor.Duration < 0 || r.StableTimeout.Duration < 0 {
+		return errors.New("config: rule " + r.Name + " has a negative stable_for or stable_timeout")
+	}
+
+	if r.StableFor.Duration > 0 && r.StableWait() < r.StableFor.Duration {
+		return errors.New("config: rule " + r.Name + " has stable_timeout shorter than stable_for")
+	}
+
+	return nil
+}
+
+// validateAction checks r's action and keeps its parsed templates.
+func validateAction(cfg *Config, r *Rule) error {
 	switch r.Action.Type {
 	case "command":
-		if r.Action.Command == "" {
-			return errors.New("config: rule " + r.Name + " command action requires a command")
-		}
+		return validateCommand(cfg, r)
 	case "webhook":
 		if r.Action.URL == "" {
 			return errors.New("config: rule " + r.Name + " webhook action requires a url")
@@ -141,11 +445,58 @@ func validateAction(r Rule) error {
 		if r.Action.Format == "" {
 			return errors.New("config: rule " + r.Name + " log action requires a format")
 		}
+
+		format, err := action.ParseTemplate(r.Action.Format)
+		if err != nil {
+			return errors.New("config: rule " + r.Name + " has invalid log format template: " + err.Error())
+		}
+
+		r.Action.format = format
+	case "livereload":
+		if r.Action.Addr != "" {
+			_, _, err := net.SplitHostPort(r.Action.Addr)
+			if err != nil {
+				return errors.New("config: rule " + r.Name + " livereload action has invalid addr " + r.Action.Addr + ": " + err.Error())
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateCommand parses r's command for the shell it runs in. An args
+// action may sit under a global shell, which it simply does not use; only
+// a shell set on the action itself is an error.
+func validateCommand(cfg *Config, r *Rule) error {
+	a := r.Action
+
+	switch {
+	case a.Command == "" && len(a.Args) == 0:
+		return errors.New("config: rule " + r.Name + " command action requires a command or args")
+	case a.Command != "" && len(a.Args) > 0:
+		return errors.New("config: rule " + r.Name + " command action sets both command and args")
+	case a.Shell != "" && len(a.Args) > 0:
+		return errors.New("config: rule " + r.Name + " command action sets shell, which args do not use")
+	}
+
+	if !isValidShell(a.Shell) {
+		return errors.New("config: rule " + r.Name + " has invalid shell " + a.Shell + ": must be " + strings.Join(action.Shells, ", "))
 	}
 
+	cmd, err := action.ParseCommand(a.Command, a.Args, cfg.Shell(a))
+	if err != nil {
+		return errors.New("config: rule " + r.Name + " has invalid command template: " + err.Error())
+	}
+
+	r.Action.command = cmd
+
 	return nil
 }
 
+func isValidShell(shell string) bool {
+	return shell == "" || slices.Contains(action.Shells, shell)
+}
+
 func itoa(i int) string {
 	if i == 0 {
 		return "0"
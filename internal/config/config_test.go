@@ -2,8 +2,11 @@ package config
 
 import (
 	"net/http"
+	"slices"
 	"testing"
 	"time"
+
+	"github.com/devaloi/watchdog/internal/watcher"
 )
 
 const actionTypeCommand = "command"
@@ -81,6 +84,28 @@ rules:
 	}
 }
 
+func TestParseDuplicateName(t *testing.T) {
+	input := `
+rules:
+  - name: "build"
+    watch: ["**/*.go"]
+    action:
+      type: command
+      command: "go build"
+  - name: "build"
+    watch: ["**/*.txt"]
+    stable_for: 1s
+    action:
+      type: command
+      command: "go build"
+`
+
+	_, err := Parse([]byte(input))
+	if err == nil {
+		t.Fatal("expected error for duplicate rule names")
+	}
+}
+
 func TestParseMissingWatch(t *testing.T) {
 	input := `
 rules:
@@ -188,9 +213,451 @@ rules:
 	}
 }
 
+func TestParseContentPredicates(t *testing.T) {
+	input := `
+rules:
+  - name: "Drafts"
+    watch: ["**/*.md"]
+    contains: "draft: true"
+    content_regex: "(?m)^title:"
+    action:
+      type: log
+      format: "{{.Path}}"
+`
+
+	cfg, err := Parse([]byte(input))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Rules[0].Contains != "draft: true" {
+		t.Errorf("contains = %q", cfg.Rules[0].Contains)
+	}
+
+	if cfg.Rules[0].ContentRegex != "(?m)^title:" {
+		t.Errorf("content_regex = %q", cfg.Rules[0].ContentRegex)
+	}
+
+	first, err := cfg.Rules[0].ParsedContent()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := cfg.Rules[0].ParsedContent()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first == nil || first != second {
+		t.Error("ParsedContent compiled again instead of returning the predicate Parse kept")
+	}
+}
+
+func TestParseInvalidPatterns(t *testing.T) {
+	inputs := map[string]string{
+		"watch regex": `
+rules:
+  - name: "test"
+    watch: ["re:(["]
+    action:
+      type: log
+      format: "x"
+`,
+		"only negations": `
+rules:
+  - name: "test"
+    watch: ["!*.go"]
+    action:
+      type: log
+      format: "x"
+`,
+		"bad class": `
+rules:
+  - name: "test"
+    watch: ["[a-"]
+    action:
+      type: log
+      format: "x"
+`,
+		"content regex": `
+rules:
+  - name: "test"
+    watch: ["*.go"]
+    content_regex: "(["
+    action:
+      type: log
+      format: "x"
+`,
+		"ignore regex": `
+global:
+  ignore: ["re:(["]
+rules:
+  - name: "test"
+    watch: ["*.go"]
+    action:
+      type: log
+      format: "x"
+`,
+	}
+
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse([]byte(input))
+			if err == nil {
+				t.Fatal("expected error for invalid pattern")
+			}
+		})
+	}
+}
+
 func TestParseInvalidYAML(t *testing.T) {
 	_, err := Parse([]byte(`{invalid yaml`))
 	if err == nil {
 		t.Fatal("expected error for invalid YAML")
 	}
 }
+
+func TestParseDisplay(t *testing.T) {
+	cfg, err := Parse([]byte(`
+global:
+  display:
+    color: never
+    ascii: true
+    theme:
+      success: bright-green
+rules:
+  - name: "test"
+    watch: ["*.go"]
+    action:
+      type: log
+      format: "x"
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	d := cfg.Global.Display
+	if d.Color != "never" || !d.ASCII || d.Theme["success"] != "bright-green" {
+		t.Errorf("display = %+v", d)
+	}
+
+	_, err = Parse([]byte(`
+global:
+  display:
+    color: sometimes
+rules:
+  - name: "test"
+    watch: ["*.go"]
+    action:
+      type: log
+      format: "x"
+`))
+	if err == nil {
+		t.Fatal("expected error for invalid display color")
+	}
+
+	for _, theme := range []string{"sucess: green", "success: bright-gren", "failure: bold redd"} {
+		_, err := Parse([]byte(`
+global:
+  display:
+    theme:
+      ` + theme + `
+rules:
+  - name: "test"
+    watch: ["*.go"]
+    action:
+      type: log
+      format: "x"
+`))
+		if err == nil {
+			t.Errorf("%q: expected error for invalid theme", theme)
+		}
+	}
+}
+
+func TestParseMetricsAddr(t *testing.T) {
+	const rules = `
+rules:
+  - name: "test"
+    watch: ["*.go"]
+    action:
+      type: log
+      format: "x"
+`
+
+	cfg, err := Parse([]byte("global:\n  metrics_addr: \"127.0.0.1:9090\"\n" + rules))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Global.MetricsAddr != "127.0.0.1:9090" {
+		t.Errorf("metrics_addr = %q", cfg.Global.MetricsAddr)
+	}
+
+	_, err = Parse([]byte("global:\n  metrics_addr: \"9090\"\n" + rules))
+	if err == nil {
+		t.Fatal("expected error for metrics_addr without a port separator")
+	}
+}
+
+func TestParseAPIAddr(t *testing.T) {
+	const rules = `
+rules:
+  - name: "test"
+    watch: ["*.go"]
+    action:
+      type: log
+      format: "x"
+`
+
+	for _, addr := range []string{"127.0.0.1:7070", "unix:/tmp/watchdog.sock"} {
+		_, err := Parse([]byte("global:\n  api_addr: \"" + addr + "\"\n" + rules))
+		if err != nil {
+			t.Errorf("api_addr %q: %v", addr, err)
+		}
+	}
+
+	for _, addr := range []string{"7070", "unix:"} {
+		_, err := Parse([]byte("global:\n  api_addr: \"" + addr + "\"\n" + rules))
+		if err == nil {
+			t.Errorf("api_addr %q: expected error", addr)
+		}
+	}
+}
+
+func TestParseEditorSaves(t *testing.T) {
+	const rules = `
+rules:
+  - name: "test"
+    watch: ["*.go"]
+    action:
+      type: log
+      format: "x"
+`
+
+	cfg, err := Parse([]byte(rules))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !cfg.Global.EditorSaves.CollapseEnabled() {
+		t.Error("collapse should default to on")
+	}
+
+	cfg, err = Parse([]byte("global:\n  editor_saves:\n    collapse: false\n    temp_patterns: [\"*.bak\"]\n" + rules))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Global.EditorSaves.CollapseEnabled() || len(cfg.Global.EditorSaves.TempPatterns) != 1 {
+		t.Errorf("editor_saves = %+v", cfg.Global.EditorSaves)
+	}
+
+	_, err = Parse([]byte("global:\n  editor_saves:\n    temp_patterns: [\"re:(\"]\n" + rules))
+	if err == nil {
+		t.Error("expected error for invalid temp pattern")
+	}
+}
+
+func TestParseStableFor(t *testing.T) {
+	rule := func(extra string) []byte {
+		return []byte(`
+rules:
+  - name: "ingest"
+    watch: ["incoming/**"]
+` + extra + `
+    action:
+      type: log
+      format: "x"
+`)
+	}
+
+	cfg, err := Parse(rule("    stable_for: 2s"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r := cfg.Rules[0]; r.StableFor.Duration != 2*time.Second || r.StableWait() != DefaultStableTimeout {
+		t.Errorf("stable_for = %v, wait = %v", r.StableFor.Duration, r.StableWait())
+	}
+
+	for _, bad := range []string{"    stable_for: -1s", "    stable_for: 2s\n    stable_timeout: 1s"} {
+		_, err := Parse(rule(bad))
+		if err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestParseEvents(t *testing.T) {
+	rule := func(events string) []byte {
+		return []byte(`
+rules:
+  - name: "upload"
+    watch: ["out/**"]
+    events: ` + events + `
+    action:
+      type: log
+      format: "x"
+`)
+	}
+
+	cfg, err := Parse(rule("[close_write, chmod]"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !cfg.ListsEvent("close_write") || cfg.ListsEvent("resync") {
+		t.Error("ListsEvent should report only the listed events")
+	}
+
+	_, err = Parse(rule("[closed]"))
+	if err == nil {
+		t.Error("expected error for unknown event")
+	}
+}
+
+func TestParseVarsAndTemplates(t *testing.T) {
+	cfg, err := Parse([]byte(`
+vars:
+  registry: ghcr.io/acme
+rules:
+  - name: "push"
+    watch: ["Dockerfile"]
+    action:
+      type: command
+      command: "docker push {{.Vars.registry}}/{{.Stem | lower}}"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Vars["registry"] != "ghcr.io/acme" {
+		t.Errorf("vars = %v", cfg.Vars)
+	}
+
+	first, err := cfg.ParsedCommand(cfg.Rules[0].Action)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := cfg.ParsedCommand(cfg.Rules[0].Action)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != second {
+		t.Error("ParsedCommand parsed again instead of returning the parse Parse kept")
+	}
+
+	for _, action := range []string{
+		"type: command\n      command: \"echo {{.Path\"",
+		"type: log\n      format: \"{{nosuchfunc .Path}}\"",
+	} {
+		_, err := Parse([]byte(`
+rules:
+  - name: "bad"
+    watch: ["*"]
+    action:
+      ` + action + `
+`))
+		if err == nil {
+			t.Errorf("%q: expected template error", action)
+		}
+	}
+}
+
+func TestParseCommandForms(t *testing.T) {
+	rule := func(action string) []byte {
+		return []byte(`
+global:
+  shell: bash
+rules:
+  - name: "fmt"
+    watch: ["**/*.go"]
+    action:
+      type: command
+` + action + `
+`)
+	}
+
+	cfg, err := Parse(rule(`      args: ["gofmt", "-w", "{{.Path}}"]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a := cfg.Rules[0].Action; len(a.Args) != 3 || cfg.Shell(a) != "bash" {
+		t.Errorf("args = %v, shell = %q", a.Args, cfg.Shell(a))
+	}
+
+	cfg, err = Parse(rule("      command: \"gofmt -w {{.Path}}\"\n      shell: pwsh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a := cfg.Rules[0].Action; cfg.Shell(a) != "pwsh" {
+		t.Errorf("shell = %q, want the rule's override", cfg.Shell(a))
+	}
+
+	cfg, err = Parse(rule(`      command: "gofmt -w {{.Path}}"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmd, err := cfg.ParsedCommand(cfg.Rules[0].Action)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	argv, err := cmd.Argv(watcher.Event{Path: "it's.go"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"bash", "-c", `gofmt -w 'it'\''s.go'`}; !slices.Equal(argv, want) {
+		t.Errorf("argv = %q, want %q parsed for the global shell", argv, want)
+	}
+
+	for _, bad := range []string{
+		"      command: \"gofmt\"\n      args: [\"gofmt\"]",
+		"      args: [\"gofmt\"]\n      shell: zsh",
+		"      command: \"gofmt\"\n      shell: fish",
+		"      args: [\"{{.Path\"]",
+		"      command: \"gofmt -w '{{.Path}}'\"",
+	} {
+		_, err := Parse(rule(bad))
+		if err == nil {
+			t.Errorf("%q: expected error", bad)
+		}
+	}
+}
+
+func TestParseLiveReloadAction(t *testing.T) {
+	cfg, err := Parse([]byte(`
+rules:
+  - name: "reload"
+    watch: ["public/**"]
+    action:
+      type: livereload
+      addr: "127.0.0.1:35729"
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if cfg.Rules[0].Action.Addr != "127.0.0.1:35729" {
+		t.Errorf("addr = %q", cfg.Rules[0].Action.Addr)
+	}
+
+	_, err = Parse([]byte(`
+rules:
+  - name: "reload"
+    watch: ["public/**"]
+    action:
+      type: livereload
+      addr: "35729"
+`))
+	if err == nil {
+		t.Fatal("expected error for invalid livereload addr")
+	}
+}
@@ -0,0 +1,187 @@
+// Package dispatch routes watcher events to rule actions. Each event is
+// matched by the rule engine and debounced per rule and path. A rule with
+// stable_for then waits for the file to settle. A removed directory drops
+// the pending triggers for files inside it. watchdog's main loop and the
+// watchdogtest harness both run events through a Dispatcher, so tests see
+// the same timing as the CLI.
+package dispatch
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/config"
+	"github.com/devaloi/watchdog/internal/rule"
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+// FireFunc runs a rule's action for an event once it is due. It is called
+// on the clock's goroutine.
+type FireFunc func(m rule.Match, ev watcher.Event)
+
+// Dispatcher turns events into debounced action runs. It is safe for
+// concurrent use.
+type Dispatcher struct {
+	// Observe, if set, is called with every dispatched event and the rules
+	// it matched, before debouncing. This includes the timeout events the
+	// Dispatcher emits itself when a stable_for wait gives up.
+	Observe func(ev watcher.Event, matches []rule.Match)
+
+	cfg    *config.Config
+	engine *rule.Engine
+	clock  watcher.Clock
+	deb    *watcher.Debouncer
+	fire   FireFunc
+
+	mu    sync.Mutex
+	seq   uint64
+	waits map[string]stableWait
+}
+
+// stableWait is a running WaitStable; seq tells a finished wait from the
+// one that replaced it.
+type stableWait struct {
+	cancel func()
+	seq    uint64
+}
+
+// New creates a Dispatcher for cfg whose debounce and stable_for timers
+// run on clock. fire runs the matched rule's action.
+func New(cfg *config.Config, clock watcher.Clock, fire FireFunc) *Dispatcher {
+	return &Dispatcher{
+		cfg:    cfg,
+		engine: rule.NewEngine(cfg),
+		clock:  clock,
+		deb:    watcher.NewDebouncerWithClock(cfg.Global.Debounce.Duration, clock),
+		fire:   fire,
+		waits:  make(map[string]stableWait),
+	}
+}
+
+// Engine returns the rule engine events are matched with.
+func (d *Dispatcher) Engine() *rule.Engine {
+	return d.engine
+}
+
+// Dispatch matches ev against the rules and schedules each matched rule's
+// action. A delete or rename first drops pending triggers for the path
+// and everything under it.
+func (d *Dispatcher) Dispatch(ev watcher.Event) {
+	if ev.Type == watcher.Delete || ev.Type == watcher.Rename {
+		d.cancelWithin(ev.Path)
+	}
+
+	matches := d.engine.Evaluate(ev)
+
+	if d.Observe != nil {
+		d.Observe(ev, matches)
+	}
+
+	for _, m := range matches {
+		r := d.rule(m.RuleName)
+		key := m.RuleName + ":" + ev.Path
+
+		d.deb.TriggerWithDelay(key, d.debounce(r), func() {
+			if r.StableFor.Duration <= 0 || ev.Type == watcher.Timeout {
+				d.fire(m, ev)
+
+				return
+			}
+
+			d.waitStable(key, r, m, ev)
+		})
+	}
+}
+
+// Stop cancels every pending trigger and stable_for wait.
+func (d *Dispatcher) Stop() {
+	d.deb.Stop()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for key, w := range d.waits {
+		w.cancel()
+		delete(d.waits, key)
+	}
+}
+
+// waitStable fires m once the event's file has been stable for the rule's
+// stable_for, or dispatches a timeout event if it never settles.
+func (d *Dispatcher) waitStable(key string, r config.Rule, m rule.Match, ev watcher.Event) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if w, ok := d.waits[key]; ok {
+		w.cancel()
+	}
+
+	d.seq++
+	seq := d.seq
+
+	cancel := watcher.WaitStable(d.clock, ev.Path, r.StableFor.Duration, r.StableWait(), func(stable bool) {
+		d.mu.Lock()
+		if w, ok := d.waits[key]; ok && w.seq == seq {
+			delete(d.waits, key)
+		}
+		d.mu.Unlock()
+
+		if stable {
+			d.fire(m, ev)
+
+			return
+		}
+
+		d.Dispatch(watcher.Event{Path: ev.Path, Type: watcher.Timeout, Name: ev.Name, Dir: ev.Dir})
+	})
+
+	d.waits[key] = stableWait{cancel: cancel, seq: seq}
+}
+
+// cancelWithin drops pending triggers and stable waits for path and
+// anything under it.
+func (d *Dispatcher) cancelWithin(path string) {
+	inside := func(key string) bool {
+		for _, r := range d.cfg.Rules {
+			p, ok := strings.CutPrefix(key, r.Name+":")
+			if ok && watcher.Within(p, path) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	d.deb.Cancel(inside)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for key, w := range d.waits {
+		if inside(key) {
+			w.cancel()
+			delete(d.waits, key)
+		}
+	}
+}
+
+// rule returns the named rule from the config.
+func (d *Dispatcher) rule(name string) config.Rule {
+	for _, r := range d.cfg.Rules {
+		if r.Name == name {
+			return r
+		}
+	}
+
+	return config.Rule{}
+}
+
+// debounce returns the rule's debounce, falling back to the global one.
+func (d *Dispatcher) debounce(r config.Rule) time.Duration {
+	if r.Debounce.Duration > 0 {
+		return r.Debounce.Duration
+	}
+
+	return d.cfg.Global.Debounce.Duration
+}
@@ -0,0 +1,120 @@
+package dispatch_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/config"
+	"github.com/devaloi/watchdog/internal/dispatch"
+	"github.com/devaloi/watchdog/internal/rule"
+	"github.com/devaloi/watchdog/internal/watcher"
+	"github.com/devaloi/watchdog/watchdogtest"
+)
+
+func TestDispatcherRealClock(t *testing.T) {
+	cfg := &config.Config{
+		Global: config.Global{Debounce: config.Duration{Duration: 20 * time.Millisecond}},
+		Rules: []config.Rule{
+			{Name: "build", Watch: []string{"**/*.go"}},
+		},
+	}
+
+	fired := make(chan watcher.Event, 2)
+
+	d := dispatch.New(cfg, watcher.RealClock{}, func(_ rule.Match, ev watcher.Event) {
+		fired <- ev
+	})
+	defer d.Stop()
+
+	var (
+		mu       sync.Mutex
+		observed []string
+	)
+
+	d.Observe = func(ev watcher.Event, matches []rule.Match) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		observed = append(observed, ev.Path+":"+strconv.Itoa(len(matches)))
+	}
+
+	for _, path := range []string{"main.go", "main.go", "README.md"} {
+		d.Dispatch(watchdogtest.Event(path, watcher.Modify))
+	}
+
+	select {
+	case ev := <-fired:
+		if ev.Path != "main.go" {
+			t.Errorf("fired for %s", ev.Path)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("debounced action did not fire")
+	}
+
+	select {
+	case ev := <-fired:
+		t.Errorf("fired again for %s", ev.Path)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if want := []string{"main.go:1", "main.go:1", "README.md:0"}; !slices.Equal(observed, want) {
+		t.Errorf("observed = %v, want %v", observed, want)
+	}
+}
+
+func TestDispatcherObservesTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "upload.csv")
+
+	cfg := &config.Config{
+		Rules: []config.Rule{
+			{
+				Name:          "ingest",
+				Watch:         []string{"**/*.csv"},
+				StableFor:     config.Duration{Duration: time.Second},
+				StableTimeout: config.Duration{Duration: 3 * time.Second},
+			},
+			{Name: "stalled", Watch: []string{"**/*.csv"}, Events: []string{"timeout"}},
+		},
+	}
+
+	clock := watchdogtest.NewClock(watchdogtest.Epoch)
+
+	var fired []string
+
+	d := dispatch.New(cfg, clock, func(m rule.Match, ev watcher.Event) {
+		fired = append(fired, m.RuleName+":"+string(ev.Type))
+	})
+	defer d.Stop()
+
+	var timeouts int
+
+	d.Observe = func(ev watcher.Event, _ []rule.Match) {
+		if ev.Type == watcher.Timeout {
+			timeouts++
+		}
+	}
+
+	d.Dispatch(watchdogtest.Event(path, watcher.Create))
+
+	// Keep the file changing until the wait gives up.
+	for i := range 16 {
+		err := os.WriteFile(path, make([]byte, i+1), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		clock.Advance(250 * time.Millisecond)
+	}
+
+	if timeouts != 1 || !slices.Equal(fired, []string{"stalled:timeout"}) {
+		t.Errorf("timeouts = %d, fired = %v", timeouts, fired)
+	}
+}
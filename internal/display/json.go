@@ -0,0 +1,202 @@
+package display
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/action"
+	"github.com/devaloi/watchdog/internal/config"
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+// SchemaVersion is the version of the JSON output schema. It is bumped on
+// any incompatible change; adding fields or record types is compatible.
+const SchemaVersion = 1
+
+// Record types emitted in JSON mode.
+const (
+	RecordStartup      = "startup"
+	RecordEvent        = "event"
+	RecordMatch        = "match"
+	RecordFiltered     = "filtered"
+	RecordActionStart  = "action_start"
+	RecordActionFinish = "action_finish"
+	RecordCommandExit  = "command_exit"
+	RecordDryRun       = "dry_run"
+	RecordOutput       = "output"
+	RecordShutdown     = "shutdown"
+)
+
+// Record is a single line of JSON output. Fields that do not apply to a
+// record type are omitted.
+type Record struct {
+	Schema     int          `json:"schema"`
+	Type       string       `json:"type"`
+	Time       string       `json:"time"`
+	Path       string       `json:"path,omitempty"`
+	Event      string       `json:"event,omitempty"`
+	Rule       string       `json:"rule,omitempty"`
+	Action     string       `json:"action,omitempty"`
+	Reason     string       `json:"reason,omitempty"`
+	OK         *bool        `json:"ok,omitempty"`
+	ExitCode   *int         `json:"exit_code,omitempty"`
+	Canceled   bool         `json:"canceled,omitempty"`
+	DurationMS *float64     `json:"duration_ms,omitempty"`
+	Error      string       `json:"error,omitempty"`
+	Stream     string       `json:"stream,omitempty"`
+	Line       *string      `json:"line,omitempty"`
+	Startup    *StartupInfo `json:"startup,omitempty"`
+}
+
+// StartupInfo describes the loaded config in a startup record.
+type StartupInfo struct {
+	Config     string     `json:"config"`
+	DebounceMS float64    `json:"debounce_ms"`
+	Ignore     []string   `json:"ignore"`
+	Rules      []RuleInfo `json:"rules"`
+}
+
+// RuleInfo describes one configured rule in a startup record.
+type RuleInfo struct {
+	Name   string   `json:"name"`
+	Action string   `json:"action"`
+	Watch  []string `json:"watch"`
+	Events []string `json:"events"`
+}
+
+// JSONOutput writes one Record per line. It is safe for concurrent use.
+type JSONOutput struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	now func() time.Time
+}
+
+// NewJSONOutput creates a JSONOutput writing to w.
+func NewJSONOutput(w io.Writer) *JSONOutput {
+	return &JSONOutput{
+		enc: json.NewEncoder(w),
+		now: time.Now,
+	}
+}
+
+// Banner writes a startup record describing the config.
+func (j *JSONOutput) Banner(cfg *config.Config, configPath string) {
+	info := &StartupInfo{
+		Config:     configPath,
+		DebounceMS: millis(cfg.Global.Debounce.Duration),
+		Ignore:     nonNil(cfg.Global.Ignore),
+		Rules:      make([]RuleInfo, 0, len(cfg.Rules)),
+	}
+
+	for _, r := range cfg.Rules {
+		info.Rules = append(info.Rules, RuleInfo{
+			Name:   r.Name,
+			Action: r.Action.Type,
+			Watch:  nonNil(r.Watch),
+			Events: nonNil(r.Events),
+		})
+	}
+
+	j.write(Record{Type: RecordStartup, Startup: info})
+}
+
+// Event writes an event record, or a match record when ruleName is set.
+func (j *JSONOutput) Event(ev watcher.Event, ruleName string) {
+	typ := RecordEvent
+	if ruleName != "" {
+		typ = RecordMatch
+	}
+
+	j.write(Record{Type: typ, Path: ev.Path, Event: string(ev.Type), Rule: ruleName})
+}
+
+// Verbose writes a filtered record.
+func (j *JSONOutput) Verbose(ev watcher.Event, reason string) {
+	j.write(Record{Type: RecordFiltered, Path: ev.Path, Event: string(ev.Type), Reason: reason})
+}
+
+// ActionStart writes an action_start record.
+func (j *JSONOutput) ActionStart(ruleName, actionType string, ev watcher.Event) {
+	j.write(Record{
+		Type:   RecordActionStart,
+		Rule:   ruleName,
+		Action: actionType,
+		Path:   ev.Path,
+		Event:  string(ev.Type),
+	})
+}
+
+// ActionResult writes an action_finish record.
+func (j *JSONOutput) ActionResult(ruleName string, err error, elapsed time.Duration) {
+	ms := millis(elapsed)
+	ok := err == nil
+
+	rec := Record{Type: RecordActionFinish, Rule: ruleName, OK: &ok, DurationMS: &ms}
+	if err != nil {
+		rec.Error = err.Error()
+	}
+
+	j.write(rec)
+}
+
+// CommandExit writes a command_exit record with the process exit code.
+func (j *JSONOutput) CommandExit(ruleName string, res action.Result) {
+	ms := millis(res.Duration)
+	ok := res.ExitCode == 0 && !res.Canceled
+	code := res.ExitCode
+
+	rec := Record{
+		Type:       RecordCommandExit,
+		Rule:       ruleName,
+		OK:         &ok,
+		ExitCode:   &code,
+		Canceled:   res.Canceled,
+		DurationMS: &ms,
+	}
+	if res.Err != nil {
+		rec.Error = res.Err.Error()
+	}
+
+	j.write(rec)
+}
+
+// DryRun writes a dry_run record.
+func (j *JSONOutput) DryRun(ruleName, actionType string) {
+	j.write(Record{Type: RecordDryRun, Rule: ruleName, Action: actionType})
+}
+
+// output writes an output record for one line of a command's stdout or
+// stderr.
+func (j *JSONOutput) output(ruleName, stream, line string) {
+	j.write(Record{Type: RecordOutput, Rule: ruleName, Stream: stream, Line: &line})
+}
+
+// Shutdown writes a shutdown record.
+func (j *JSONOutput) Shutdown() {
+	j.write(Record{Type: RecordShutdown})
+}
+
+func (j *JSONOutput) write(rec Record) {
+	rec.Schema = SchemaVersion
+	rec.Time = j.now().UTC().Format(time.RFC3339Nano)
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	_ = j.enc.Encode(rec)
+}
+
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// nonNil keeps empty lists as [] rather than null in the output.
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+
+	return s
+}
@@ -0,0 +1,144 @@
+package display
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/action"
+	"github.com/devaloi/watchdog/internal/config"
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+func TestJSONOutputRecords(t *testing.T) {
+	var buf bytes.Buffer
+
+	out := NewJSONOutput(&buf)
+
+	cfg := &config.Config{
+		Global: config.Global{Debounce: config.Duration{Duration: 500 * time.Millisecond}},
+		Rules: []config.Rule{
+			{Name: "build", Watch: []string{"**/*.go"}, Action: config.Action{Type: "command"}},
+		},
+	}
+
+	ev := watcher.Event{Path: "main.go", Type: watcher.Modify}
+
+	out.Banner(cfg, "watchdog.yaml")
+	out.Event(ev, "")
+	out.Event(ev, "build")
+	out.Verbose(ev, "ignored")
+	out.ActionStart("build", "command", ev)
+	out.ActionResult("build", errors.New("boom"), 2*time.Millisecond)
+	out.CommandExit("build", action.Result{ExitCode: 2, Duration: 1500 * time.Millisecond})
+	out.DryRun("build", "command")
+	out.Shutdown()
+
+	var records []Record
+
+	sc := bufio.NewScanner(&buf)
+	for sc.Scan() {
+		var rec Record
+
+		err := json.Unmarshal(sc.Bytes(), &rec)
+		if err != nil {
+			t.Fatalf("invalid JSON line %q: %v", sc.Text(), err)
+		}
+
+		records = append(records, rec)
+	}
+
+	wantTypes := []string{
+		RecordStartup, RecordEvent, RecordMatch, RecordFiltered, RecordActionStart,
+		RecordActionFinish, RecordCommandExit, RecordDryRun, RecordShutdown,
+	}
+
+	if len(records) != len(wantTypes) {
+		t.Fatalf("got %d records, want %d", len(records), len(wantTypes))
+	}
+
+	for i, rec := range records {
+		if rec.Type != wantTypes[i] {
+			t.Errorf("record %d type = %q, want %q", i, rec.Type, wantTypes[i])
+		}
+
+		if rec.Schema != SchemaVersion {
+			t.Errorf("record %d schema = %d, want %d", i, rec.Schema, SchemaVersion)
+		}
+	}
+
+	if records[0].Startup == nil || records[0].Startup.DebounceMS != 500 || records[0].Startup.Rules[0].Name != "build" {
+		t.Errorf("unexpected startup record: %+v", records[0].Startup)
+	}
+
+	if records[2].Rule != "build" || records[2].Path != "main.go" {
+		t.Errorf("unexpected match record: %+v", records[2])
+	}
+
+	if records[5].OK == nil || *records[5].OK || records[5].Error != "boom" {
+		t.Errorf("unexpected action_finish record: %+v", records[5])
+	}
+
+	exit := records[6]
+	if exit.ExitCode == nil || *exit.ExitCode != 2 || exit.DurationMS == nil || *exit.DurationMS != 1500 {
+		t.Errorf("unexpected command_exit record: %+v", exit)
+	}
+}
+
+func TestNewPrinterFormats(t *testing.T) {
+	for _, format := range []string{"", FormatText, FormatJSON} {
+		_, err := New(format, &bytes.Buffer{}, DefaultStyle)
+		if err != nil {
+			t.Errorf("New(%q): %v", format, err)
+		}
+	}
+
+	_, err := New("xml", &bytes.Buffer{}, DefaultStyle)
+	if err == nil {
+		t.Error("expected error for unknown format")
+	}
+}
+
+func TestJSONCommandOutputRecords(t *testing.T) {
+	var buf bytes.Buffer
+
+	out := NewJSONCommandOutput(NewJSONOutput(&buf), config.CommandOutput{})
+
+	rw, err := out.Writers("build")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = rw.Stdout.Write([]byte("compiling\n\nok"))
+	_, _ = rw.Stderr.Write([]byte("warning: {\"x\"}\n"))
+
+	err = rw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []struct{ stream, line string }{
+		{"stdout", "compiling"},
+		{"stdout", ""},
+		{"stderr", `warning: {"x"}`},
+		{"stdout", "ok"},
+	}
+
+	sc := bufio.NewScanner(&buf)
+
+	for i := 0; sc.Scan(); i++ {
+		var rec Record
+
+		err := json.Unmarshal(sc.Bytes(), &rec)
+		if err != nil {
+			t.Fatalf("line %d is not JSON: %v", i, err)
+		}
+
+		if i >= len(want) || rec.Type != RecordOutput || rec.Rule != "build" || rec.Stream != want[i].stream || rec.Line == nil || *rec.Line != want[i].line {
+			t.Errorf("record %d = %+v", i, rec)
+		}
+	}
+}
@@ -4,71 +4,81 @@ package display
 import (
 	"io"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/devaloi/watchdog/internal/action"
 	"github.com/devaloi/watchdog/internal/config"
 	"github.com/devaloi/watchdog/internal/watcher"
 )
 
 const (
-	colorReset  = "\033[0m"
-	colorGreen  = "\033[32m"
-	colorYellow = "\033[33m"
-	colorRed    = "\033[31m"
-	colorBlue   = "\033[34m"
-	colorCyan   = "\033[36m"
-	colorDim    = "\033[2m"
-	colorBold   = "\033[1m"
+	colorReset   = "\033[0m"
+	colorGreen   = "\033[32m"
+	colorYellow  = "\033[33m"
+	colorRed     = "\033[31m"
+	colorBlue    = "\033[34m"
+	colorMagenta = "\033[35m"
+	colorCyan    = "\033[36m"
+	colorDim     = "\033[2m"
+	colorBold    = "\033[1m"
 )
 
-// Output writes formatted event information to the terminal.
+// Output writes formatted event information to the terminal. A nil Style
+// means DefaultStyle.
 type Output struct {
 	Writer io.Writer
+	Style  *Style
 }
 
-// NewOutput creates an Output writing to stdout.
+// NewOutput creates an Output writing to stdout, colored only when stdout
+// is a terminal and NO_COLOR is unset.
 func NewOutput() *Output {
-	return &Output{Writer: os.Stdout}
+	style, _ := NewStyle(os.Stdout, ColorAuto, config.Display{})
+
+	return &Output{Writer: os.Stdout, Style: &style}
 }
 
 // Banner prints the startup banner with watched paths and rules.
 func (o *Output) Banner(cfg *config.Config, configPath string) {
 	w := o.Writer
+	s := o.style()
+	g := s.Glyphs
 
 	write(w, "\n")
-	write(w, colorBold+"  🐕 watchdog"+colorReset+" — file system watcher\n")
-	write(w, colorDim+"  config: "+configPath+colorReset+"\n")
+	write(w, "  "+s.paint(s.Theme.Title, g.Logo+"watchdog")+" "+g.Dash+" file system watcher\n")
+	write(w, s.paint(s.Theme.Muted, "  config: "+configPath)+"\n")
 
 	if cfg.Global.Debounce.Duration > 0 {
-		write(w, colorDim+"  debounce: "+cfg.Global.Debounce.String()+colorReset+"\n")
+		write(w, s.paint(s.Theme.Muted, "  debounce: "+cfg.Global.Debounce.String())+"\n")
 	}
 
 	if len(cfg.Global.Ignore) > 0 {
-		write(w, colorDim+"  ignore: "+strings.Join(cfg.Global.Ignore, ", ")+colorReset+"\n")
+		write(w, s.paint(s.Theme.Muted, "  ignore: "+strings.Join(cfg.Global.Ignore, ", "))+"\n")
 	}
 
 	write(w, "\n")
 
 	for _, r := range cfg.Rules {
-		write(w, "  "+colorCyan+"▸"+colorReset+" "+r.Name)
-		write(w, colorDim+" ["+r.Action.Type+"]"+colorReset)
-		write(w, colorDim+" "+strings.Join(r.Watch, ", ")+colorReset+"\n")
+		write(w, "  "+s.paint(s.Theme.Accent, g.Bullet)+" "+r.Name)
+		write(w, s.paint(s.Theme.Muted, " ["+r.Action.Type+"]"))
+		write(w, s.paint(s.Theme.Muted, " "+strings.Join(r.Watch, ", "))+"\n")
 	}
 
 	write(w, "\n")
-	write(w, colorDim+"  Watching for changes... (Ctrl+C to stop)"+colorReset+"\n\n")
+	write(w, s.paint(s.Theme.Muted, "  Watching for changes... (Ctrl+C to stop)")+"\n\n")
 }
 
 // Event prints a colorized event line.
 func (o *Output) Event(ev watcher.Event, ruleName string) {
-	color := colorForEvent(ev.Type)
+	s := o.style()
 	ts := time.Now().Format("15:04:05")
 
-	write(o.Writer, colorDim+ts+colorReset+" "+color+string(ev.Type)+colorReset+" "+ev.Path)
+	write(o.Writer, s.paint(s.Theme.Muted, ts)+" "+s.paint(colorForEvent(s.Theme, ev.Type), string(ev.Type))+" "+ev.Path)
 
 	if ruleName != "" {
-		write(o.Writer, colorDim+" → "+ruleName+colorReset)
+		write(o.Writer, s.paint(s.Theme.Muted, " "+s.Glyphs.Arrow+" "+ruleName))
 	}
 
 	write(o.Writer, "\n")
@@ -76,45 +86,90 @@ func (o *Output) Event(ev watcher.Event, ruleName string) {
 
 // ActionResult prints the result of an action execution.
 func (o *Output) ActionResult(ruleName string, err error, elapsed time.Duration) {
+	s := o.style()
+
 	if err != nil {
-		write(o.Writer, "  "+colorRed+"✗"+colorReset+" "+ruleName+": "+err.Error()+"\n")
+		write(o.Writer, "  "+s.paint(s.Theme.Failure, s.Glyphs.Failure)+" "+ruleName+": "+err.Error()+"\n")
 
 		return
 	}
 
-	write(o.Writer, "  "+colorGreen+"✓"+colorReset+" "+ruleName+
-		colorDim+" ("+elapsed.Truncate(time.Millisecond).String()+")"+colorReset+"\n")
+	write(o.Writer, "  "+s.paint(s.Theme.Success, s.Glyphs.Success)+" "+ruleName+
+		s.paint(s.Theme.Muted, " ("+elapsed.Truncate(time.Millisecond).String()+")")+"\n")
+}
+
+// ActionStart is silent in text mode; the matching event line already names the rule.
+func (o *Output) ActionStart(string, string, watcher.Event) {}
+
+// CommandExit prints the exit status of a finished command. Runs killed
+// because the rule re-triggered are not reported.
+func (o *Output) CommandExit(ruleName string, res action.Result) {
+	if res.Canceled {
+		return
+	}
+
+	s := o.style()
+	elapsed := s.paint(s.Theme.Muted, " ("+res.Duration.Truncate(time.Millisecond).String()+")")
+
+	if res.ExitCode != 0 {
+		write(o.Writer, "  "+s.paint(s.Theme.Failure, s.Glyphs.Failure)+" "+ruleName+" exited "+strconv.Itoa(res.ExitCode)+elapsed+"\n")
+
+		return
+	}
+
+	write(o.Writer, "  "+s.paint(s.Theme.Success, s.Glyphs.Success)+" "+ruleName+" exited 0"+elapsed+"\n")
 }
 
 // DryRun prints a dry-run notice instead of executing the action.
 func (o *Output) DryRun(ruleName, actionType string) {
-	write(o.Writer, "  "+colorYellow+"[DRY RUN]"+colorReset+" would execute: "+ruleName+" ("+actionType+")\n")
+	s := o.style()
+
+	write(o.Writer, "  "+s.paint(s.Theme.Warning, "[DRY RUN]")+" would execute: "+ruleName+" ("+actionType+")\n")
 }
 
 // Verbose prints filtered events that would otherwise be hidden.
 func (o *Output) Verbose(ev watcher.Event, reason string) {
+	s := o.style()
 	ts := time.Now().Format("15:04:05")
 
-	write(o.Writer, colorDim+ts+" [filtered] "+string(ev.Type)+" "+ev.Path+" ("+reason+")"+colorReset+"\n")
+	write(o.Writer, s.paint(s.Theme.Muted, ts+" [filtered] "+string(ev.Type)+" "+ev.Path+" ("+reason+")")+"\n")
 }
 
 // Shutdown prints a clean exit message.
 func (o *Output) Shutdown() {
-	write(o.Writer, "\n"+colorDim+"  Shutting down..."+colorReset+"\n")
+	s := o.style()
+
+	write(o.Writer, "\n"+s.paint(s.Theme.Muted, "  Shutting down...")+"\n")
+}
+
+func (o *Output) style() *Style {
+	if o.Style == nil {
+		return &DefaultStyle
+	}
+
+	return o.Style
 }
 
-func colorForEvent(t watcher.EventType) string {
+func colorForEvent(theme Theme, t watcher.EventType) string {
 	switch t {
 	case watcher.Create:
-		return colorGreen
+		return theme.Create
 	case watcher.Modify:
-		return colorYellow
+		return theme.Modify
 	case watcher.Delete:
-		return colorRed
+		return theme.Delete
 	case watcher.Rename:
-		return colorBlue
+		return theme.Rename
+	case watcher.Resync:
+		return theme.Warning
+	case watcher.Timeout:
+		return theme.Failure
+	case watcher.Chmod:
+		return theme.Chmod
+	case watcher.CloseWrite:
+		return theme.CloseWrite
 	default:
-		return colorReset
+		return ""
 	}
 }
 
@@ -0,0 +1,255 @@
+package display
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"hash/fnv"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/devaloi/watchdog/internal/config"
+)
+
+// prefixColors is the palette rule prefixes are assigned from.
+var prefixColors = []string{
+	"\033[36m", "\033[35m", "\033[33m", "\033[34m", "\033[32m",
+	"\033[96m", "\033[95m", "\033[93m", "\033[94m", "\033[92m",
+}
+
+// RuleColor returns a stable color for a rule name, so a rule keeps its
+// color across restarts.
+func RuleColor(name string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+
+	return prefixColors[h.Sum32()%uint32(len(prefixColors))]
+}
+
+// CommandOutput builds per-rule writers for command actions that share one
+// destination. Lines from different rules never interleave mid-line.
+type CommandOutput struct {
+	cfg   config.CommandOutput
+	color bool
+	dst   io.Writer
+	json  *JSONOutput
+	mu    sync.Mutex
+}
+
+// NewCommandOutput creates a CommandOutput writing to dst. color enables
+// ANSI colors in the prefixes.
+func NewCommandOutput(dst io.Writer, cfg config.CommandOutput, color bool) *CommandOutput {
+	return &CommandOutput{cfg: cfg, color: color, dst: dst}
+}
+
+// NewJSONCommandOutput creates a CommandOutput that writes each line as an
+// output record to j, so command output never breaks the JSON stream.
+// Stdout and stderr are always told apart by the record's stream.
+func NewJSONCommandOutput(j *JSONOutput, cfg config.CommandOutput) *CommandOutput {
+	return &CommandOutput{cfg: cfg, json: j}
+}
+
+// RuleWriters are the stdout and stderr writers for one rule's command.
+type RuleWriters struct {
+	Stdout io.Writer
+	Stderr io.Writer
+	log    *os.File
+}
+
+// Writers returns the writers for a rule. When a log directory is
+// configured, raw output is also appended to <log_dir>/<rule>.log.
+func (c *CommandOutput) Writers(rule string) (*RuleWriters, error) {
+	rw := &RuleWriters{}
+
+	var logW io.Writer
+
+	if c.cfg.LogDir != "" {
+		err := os.MkdirAll(c.cfg.LogDir, 0o750)
+		if err != nil {
+			return nil, err
+		}
+
+		path := filepath.Join(c.cfg.LogDir, LogFileName(rule))
+
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600) //nolint:gosec // log_dir is user-configured
+		if err != nil {
+			return nil, err
+		}
+
+		rw.log = f
+		logW = f
+	}
+
+	if c.json != nil {
+		rw.Stdout = c.recordWriter(rule, "stdout", logW)
+		rw.Stderr = c.recordWriter(rule, "stderr", logW)
+
+		return rw, nil
+	}
+
+	rw.Stdout = c.lineWriter(c.prefix(rule, false), logW)
+	rw.Stderr = rw.Stdout
+
+	if c.cfg.Stderr {
+		rw.Stderr = c.lineWriter(c.prefix(rule, true), logW)
+	}
+
+	return rw, nil
+}
+
+// Close flushes partial lines and closes the rule's log file.
+func (rw *RuleWriters) Close() error {
+	var errs []error
+
+	for _, w := range []io.Writer{rw.Stdout, rw.Stderr} {
+		if lw, ok := w.(*LineWriter); ok {
+			errs = append(errs, lw.Flush())
+		}
+	}
+
+	if rw.log != nil {
+		errs = append(errs, rw.log.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// LogFileName returns the log file name for a rule, replacing characters
+// that are unsafe in file names. A name that had to be changed gets a
+// hash of the rule name appended, so "a b" and "a_b" get different files.
+func LogFileName(rule string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		default:
+			return '_'
+		}
+	}, rule)
+
+	name = strings.Trim(name, ".")
+	if name == rule {
+		return name + ".log"
+	}
+
+	if name == "" {
+		name = "rule"
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(rule))
+
+	return name + "-" + hex.EncodeToString(h.Sum(nil)) + ".log"
+}
+
+func (c *CommandOutput) prefix(rule string, stderr bool) string {
+	if !c.cfg.PrefixEnabled() {
+		if stderr {
+			return c.paint(colorRed, "[stderr]") + " "
+		}
+
+		return ""
+	}
+
+	label := "[" + rule + "]"
+	if stderr {
+		label = "[" + rule + ":err]"
+	}
+
+	return c.paint(RuleColor(rule), label) + " "
+}
+
+func (c *CommandOutput) paint(color, s string) string {
+	if !c.color {
+		return s
+	}
+
+	return color + s + colorReset
+}
+
+func (c *CommandOutput) lineWriter(prefix string, log io.Writer) *LineWriter {
+	return &LineWriter{prefix: prefix, dst: c.dst, log: log, mu: &c.mu}
+}
+
+func (c *CommandOutput) recordWriter(rule, stream string, log io.Writer) *LineWriter {
+	return &LineWriter{
+		log: log,
+		mu:  &c.mu,
+		record: func(line string) {
+			c.json.output(rule, stream, line)
+		},
+	}
+}
+
+// LineWriter buffers writes until a full line is available, then writes
+// the line with a prefix to its destination and unmodified to its log.
+// With record set, lines go to record without their newline instead.
+type LineWriter struct {
+	prefix string
+	dst    io.Writer
+	log    io.Writer
+	record func(line string)
+	mu     *sync.Mutex
+	buf    []byte
+}
+
+// Write buffers p and emits every complete line.
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.buf = append(w.buf, p...)
+
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+
+		err := w.emit(w.buf[:i+1])
+		w.buf = w.buf[i+1:]
+
+		if err != nil {
+			return len(p), err
+		}
+	}
+
+	return len(p), nil
+}
+
+// Flush emits any buffered partial line, terminated with a newline.
+func (w *LineWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if len(w.buf) == 0 {
+		return nil
+	}
+
+	line := append(w.buf, '\n')
+	w.buf = nil
+
+	return w.emit(line)
+}
+
+func (w *LineWriter) emit(line []byte) error {
+	if w.log != nil {
+		_, err := w.log.Write(line)
+		if err != nil {
+			return err
+		}
+	}
+
+	if w.record != nil {
+		w.record(strings.TrimSuffix(string(line), "\n"))
+
+		return nil
+	}
+
+	_, err := io.WriteString(w.dst, w.prefix+string(line))
+
+	return err
+}
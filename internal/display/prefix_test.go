@@ -0,0 +1,140 @@
+package display
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/devaloi/watchdog/internal/config"
+)
+
+func TestCommandOutputPrefixesLines(t *testing.T) {
+	var buf bytes.Buffer
+
+	out := NewCommandOutput(&buf, config.CommandOutput{}, false)
+
+	rw, err := out.Writers("build")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = io.WriteString(rw.Stdout, "compiling\npart")
+	_, _ = io.WriteString(rw.Stderr, "ial\n")
+	_, _ = io.WriteString(rw.Stdout, "tail")
+
+	err = rw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "[build] compiling\n[build] partial\n[build] tail\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestCommandOutputStderrMarker(t *testing.T) {
+	var buf bytes.Buffer
+
+	out := NewCommandOutput(&buf, config.CommandOutput{Stderr: true}, false)
+
+	rw, err := out.Writers("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = io.WriteString(rw.Stdout, "ok\n")
+	_, _ = io.WriteString(rw.Stderr, "FAIL\n")
+
+	want := "[test] ok\n[test:err] FAIL\n"
+	if buf.String() != want {
+		t.Errorf("output = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestCommandOutputPrefixDisabled(t *testing.T) {
+	var buf bytes.Buffer
+
+	off := false
+	out := NewCommandOutput(&buf, config.CommandOutput{Prefix: &off}, false)
+
+	rw, err := out.Writers("build")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = io.WriteString(rw.Stdout, "plain\n")
+
+	if buf.String() != "plain\n" {
+		t.Errorf("output = %q, want unprefixed line", buf.String())
+	}
+}
+
+func TestCommandOutputTeesToLogFile(t *testing.T) {
+	var buf bytes.Buffer
+
+	dir := filepath.Join(t.TempDir(), "logs")
+	out := NewCommandOutput(&buf, config.CommandOutput{LogDir: dir, Stderr: true}, true)
+
+	rw, err := out.Writers("Go rebuild")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, _ = io.WriteString(rw.Stdout, "line one\n")
+	_, _ = io.WriteString(rw.Stderr, "line two\n")
+
+	err = rw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, LogFileName("Go rebuild")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != "line one\nline two\n" {
+		t.Errorf("log file = %q, want raw unprefixed lines", data)
+	}
+
+	if !strings.Contains(buf.String(), RuleColor("Go rebuild")+"[Go rebuild]") {
+		t.Errorf("expected colored prefix in %q", buf.String())
+	}
+}
+
+func TestRuleColorStable(t *testing.T) {
+	if RuleColor("build") != RuleColor("build") {
+		t.Error("expected the same color for the same rule")
+	}
+}
+
+func TestLogFileName(t *testing.T) {
+	tests := map[string]string{
+		"build":        "build.log",
+		"Go_rebuild":   "Go_rebuild.log",
+		"Go rebuild":   "Go_rebuild-",
+		"../escape/me": "_escape_me-",
+		"..":           "rule-",
+	}
+
+	for rule, want := range tests {
+		if got := LogFileName(rule); !strings.HasPrefix(got, want) || !strings.HasSuffix(got, ".log") || strings.Contains(got, "/") {
+			t.Errorf("LogFileName(%q) = %q, want %q...", rule, got, want)
+		}
+	}
+
+	seen := make(map[string]string)
+
+	for _, rule := range []string{"a b", "a_b", "a/b", "a:b", "..", ".", "...", "build"} {
+		name := LogFileName(rule)
+		if other, ok := seen[name]; ok {
+			t.Errorf("%q and %q share log file %q", rule, other, name)
+		}
+
+		seen[name] = rule
+	}
+}
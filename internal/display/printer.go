@@ -0,0 +1,57 @@
+package display
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/action"
+	"github.com/devaloi/watchdog/internal/config"
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+// Output formats accepted by New.
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Printer reports watcher lifecycle events. Output renders them for humans
+// and JSONOutput as one JSON object per line for tools.
+type Printer interface {
+	// Banner reports startup with the loaded config.
+	Banner(cfg *config.Config, configPath string)
+	// Event reports a file system event; ruleName is set when a rule matched.
+	Event(ev watcher.Event, ruleName string)
+	// Verbose reports an event that was filtered out and why.
+	Verbose(ev watcher.Event, reason string)
+	// ActionStart reports that a rule's action is about to run.
+	ActionStart(ruleName, actionType string, ev watcher.Event)
+	// ActionResult reports that an action returned. Command actions return
+	// once the process has started; see CommandExit.
+	ActionResult(ruleName string, err error, elapsed time.Duration)
+	// CommandExit reports that a command started by a rule has exited.
+	CommandExit(ruleName string, res action.Result)
+	// DryRun reports an action that was skipped because of dry-run mode.
+	DryRun(ruleName, actionType string)
+	// Shutdown reports a clean exit.
+	Shutdown()
+}
+
+var (
+	_ Printer = (*Output)(nil)
+	_ Printer = (*JSONOutput)(nil)
+)
+
+// New creates a Printer for the given format ("text" or "json") writing to
+// w. The style applies to text output only; JSON is never colored.
+func New(format string, w io.Writer, style Style) (Printer, error) {
+	switch format {
+	case "", FormatText:
+		return &Output{Writer: w, Style: &style}, nil
+	case FormatJSON:
+		return NewJSONOutput(w), nil
+	default:
+		return nil, errors.New("display: unknown output format: " + format)
+	}
+}
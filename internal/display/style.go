@@ -0,0 +1,253 @@
+package display
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/devaloi/watchdog/internal/config"
+	"golang.org/x/term"
+)
+
+// ColorMode selects when ANSI colors are written.
+type ColorMode string
+
+// Color modes accepted by --color and global.display.color.
+const (
+	ColorAuto   ColorMode = "auto"
+	ColorAlways ColorMode = "always"
+	ColorNever  ColorMode = "never"
+)
+
+// ParseColorMode parses a --color value. An empty string means auto.
+func ParseColorMode(s string) (ColorMode, error) {
+	switch ColorMode(s) {
+	case "", ColorAuto:
+		return ColorAuto, nil
+	case ColorAlways, ColorNever:
+		return ColorMode(s), nil
+	default:
+		return "", errors.New("display: invalid color mode " + s + ": must be auto, always or never")
+	}
+}
+
+// UseColor reports whether output to w should be colored. In auto mode
+// NO_COLOR disables color, FORCE_COLOR enables it, and otherwise color is
+// used only when w is a terminal and TERM is not "dumb".
+func UseColor(mode ColorMode, w io.Writer) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	if force := os.Getenv("FORCE_COLOR"); force != "" {
+		return force != "0" && force != "false"
+	}
+
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+
+	return isTerminal(w)
+}
+
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(interface{ Fd() uintptr })
+	if !ok {
+		return false
+	}
+
+	return term.IsTerminal(int(f.Fd())) //nolint:gosec // file descriptors fit in an int
+}
+
+// Theme holds the ANSI sequence used for each role in the output.
+type Theme struct {
+	Create     string
+	Modify     string
+	Delete     string
+	Rename     string
+	Chmod      string
+	CloseWrite string
+	Success    string
+	Failure    string
+	Warning    string
+	Accent     string
+	Muted      string
+	Title      string
+}
+
+// DefaultTheme is the theme used when the config does not override it.
+var DefaultTheme = Theme{
+	Create:     colorGreen,
+	Modify:     colorYellow,
+	Delete:     colorRed,
+	Rename:     colorBlue,
+	Chmod:      colorMagenta,
+	CloseWrite: colorCyan,
+	Success:    colorGreen,
+	Failure:    colorRed,
+	Warning:    colorYellow,
+	Accent:     colorCyan,
+	Muted:      colorDim,
+	Title:      colorBold,
+}
+
+// colorNames maps the color names accepted in a theme to ANSI sequences.
+var colorNames = map[string]string{
+	"none":           "",
+	"bold":           colorBold,
+	"dim":            colorDim,
+	"italic":         "\033[3m",
+	"underline":      "\033[4m",
+	"black":          "\033[30m",
+	"red":            colorRed,
+	"green":          colorGreen,
+	"yellow":         colorYellow,
+	"blue":           colorBlue,
+	"magenta":        colorMagenta,
+	"cyan":           colorCyan,
+	"white":          "\033[37m",
+	"gray":           "\033[90m",
+	"bright-red":     "\033[91m",
+	"bright-green":   "\033[92m",
+	"bright-yellow":  "\033[93m",
+	"bright-blue":    "\033[94m",
+	"bright-magenta": "\033[95m",
+	"bright-cyan":    "\033[96m",
+	"bright-white":   "\033[97m",
+}
+
+// role returns the theme field for a role name, or nil if unknown.
+func (t *Theme) role(name string) *string {
+	switch name {
+	case "create":
+		return &t.Create
+	case "modify":
+		return &t.Modify
+	case "delete":
+		return &t.Delete
+	case "rename":
+		return &t.Rename
+	case "chmod":
+		return &t.Chmod
+	case "close_write":
+		return &t.CloseWrite
+	case "success":
+		return &t.Success
+	case "failure":
+		return &t.Failure
+	case "warning":
+		return &t.Warning
+	case "accent":
+		return &t.Accent
+	case "muted":
+		return &t.Muted
+	case "title":
+		return &t.Title
+	default:
+		return nil
+	}
+}
+
+// ParseTheme applies overrides to DefaultTheme. Each override maps a role
+// to space-separated color names, e.g. "bold bright-cyan".
+func ParseTheme(overrides map[string]string) (Theme, error) {
+	theme := DefaultTheme
+
+	roles := make([]string, 0, len(overrides))
+	for role := range overrides {
+		roles = append(roles, role)
+	}
+
+	sort.Strings(roles)
+
+	for _, role := range roles {
+		field := theme.role(role)
+		if field == nil {
+			return Theme{}, errors.New("display: unknown theme role: " + role)
+		}
+
+		var seq strings.Builder
+
+		for _, name := range strings.Fields(overrides[role]) {
+			code, ok := colorNames[name]
+			if !ok {
+				return Theme{}, errors.New("display: unknown color " + name + " for theme role " + role)
+			}
+
+			seq.WriteString(code)
+		}
+
+		*field = seq.String()
+	}
+
+	return theme, nil
+}
+
+// Glyphs are the symbols used in the banner and status lines.
+type Glyphs struct {
+	Logo    string
+	Bullet  string
+	Arrow   string
+	Dash    string
+	Success string
+	Failure string
+}
+
+// Glyph sets for Unicode terminals and for plain ASCII output.
+var (
+	UnicodeGlyphs = Glyphs{Logo: "🐕 ", Bullet: "▸", Arrow: "→", Dash: "—", Success: "✓", Failure: "✗"}
+	ASCIIGlyphs   = Glyphs{Logo: "", Bullet: ">", Arrow: "->", Dash: "-", Success: "OK", Failure: "FAIL"}
+)
+
+// Style is the resolved presentation for text output.
+type Style struct {
+	Color  bool
+	Theme  Theme
+	Glyphs Glyphs
+}
+
+// DefaultStyle is colored Unicode output with the default theme.
+var DefaultStyle = Style{Color: true, Theme: DefaultTheme, Glyphs: UnicodeGlyphs}
+
+// NewStyle resolves the style for output to w. The mode, typically from
+// --color, takes precedence over the config's color setting unless it is auto.
+func NewStyle(w io.Writer, mode ColorMode, cfg config.Display) (Style, error) {
+	if mode == "" || mode == ColorAuto {
+		m, err := ParseColorMode(cfg.Color)
+		if err != nil {
+			return Style{}, err
+		}
+
+		mode = m
+	}
+
+	theme, err := ParseTheme(cfg.Theme)
+	if err != nil {
+		return Style{}, err
+	}
+
+	glyphs := UnicodeGlyphs
+	if cfg.ASCII {
+		glyphs = ASCIIGlyphs
+	}
+
+	return Style{Color: UseColor(mode, w), Theme: theme, Glyphs: glyphs}, nil
+}
+
+// paint wraps s in color when the style is colored.
+func (s *Style) paint(color, text string) string {
+	if !s.Color || color == "" {
+		return text
+	}
+
+	return color + text + colorReset
+}
@@ -0,0 +1,164 @@
+package display
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/config"
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+func TestUseColor(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    ColorMode
+		noColor string
+		force   string
+		want    bool
+	}{
+		{"auto non-tty", ColorAuto, "", "", false},
+		{"auto force", ColorAuto, "", "1", true},
+		{"auto force zero", ColorAuto, "", "0", false},
+		{"auto no_color wins", ColorAuto, "1", "1", false},
+		{"always", ColorAlways, "1", "", true},
+		{"never", ColorNever, "", "1", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", tt.noColor)
+			t.Setenv("FORCE_COLOR", tt.force)
+
+			got := UseColor(tt.mode, &bytes.Buffer{})
+			if got != tt.want {
+				t.Errorf("UseColor(%q) = %v, want %v", tt.mode, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseColorMode(t *testing.T) {
+	for _, s := range []string{"", "auto", "always", "never"} {
+		_, err := ParseColorMode(s)
+		if err != nil {
+			t.Errorf("ParseColorMode(%q): %v", s, err)
+		}
+	}
+
+	_, err := ParseColorMode("yes")
+	if err == nil {
+		t.Error("expected error for invalid color mode")
+	}
+}
+
+func TestParseTheme(t *testing.T) {
+	theme, err := ParseTheme(map[string]string{"success": "bold bright-green", "muted": "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if theme.Success != colorBold+"\033[92m" {
+		t.Errorf("success = %q", theme.Success)
+	}
+
+	if theme.Muted != "" {
+		t.Errorf("muted = %q, want empty", theme.Muted)
+	}
+
+	if theme.Failure != DefaultTheme.Failure {
+		t.Errorf("failure = %q, want default", theme.Failure)
+	}
+
+	_, err = ParseTheme(map[string]string{"banner": "red"})
+	if err == nil {
+		t.Error("expected error for unknown role")
+	}
+
+	_, err = ParseTheme(map[string]string{"success": "chartreuse"})
+	if err == nil {
+		t.Error("expected error for unknown color")
+	}
+}
+
+func TestThemeMatchesConfig(t *testing.T) {
+	var theme Theme
+
+	for _, role := range config.ThemeRoles {
+		if theme.role(role) == nil {
+			t.Errorf("config role %q has no theme field", role)
+		}
+	}
+
+	for _, name := range config.ThemeColors {
+		if _, ok := colorNames[name]; !ok {
+			t.Errorf("config color %q has no ANSI sequence", name)
+		}
+	}
+
+	if len(colorNames) != len(config.ThemeColors) {
+		t.Errorf("%d color names, config accepts %d", len(colorNames), len(config.ThemeColors))
+	}
+}
+
+func TestOutputPlainASCII(t *testing.T) {
+	t.Setenv("NO_COLOR", "1")
+
+	var buf bytes.Buffer
+
+	style, err := NewStyle(&buf, ColorAuto, config.Display{ASCII: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out := &Output{Writer: &buf, Style: &style}
+
+	cfg := &config.Config{Rules: []config.Rule{{Name: "build", Watch: []string{"*.go"}, Action: config.Action{Type: "command"}}}}
+	out.Banner(cfg, "watchdog.yaml")
+	out.Event(watcher.Event{Path: "main.go", Type: watcher.Modify}, "build")
+	out.ActionResult("build", nil, time.Millisecond)
+	out.ActionResult("build", errors.New("boom"), time.Millisecond)
+
+	got := buf.String()
+
+	if strings.Contains(got, "\033[") {
+		t.Errorf("output contains ANSI escapes: %q", got)
+	}
+
+	for _, r := range got {
+		if r > 127 {
+			t.Fatalf("output contains non-ASCII %q: %q", r, got)
+		}
+	}
+
+	for _, want := range []string{"> build", "main.go -> build", "OK build", "FAIL build: boom"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output missing %q:\n%s", want, got)
+		}
+	}
+}
+
+func TestNewStyleFlagOverridesConfig(t *testing.T) {
+	style, err := NewStyle(&bytes.Buffer{}, ColorAlways, config.Display{Color: "never"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !style.Color {
+		t.Error("--color=always should override config color: never")
+	}
+
+	t.Setenv("FORCE_COLOR", "1")
+	t.Setenv("NO_COLOR", "")
+
+	style, err = NewStyle(&bytes.Buffer{}, ColorAuto, config.Display{Color: "never"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if style.Color {
+		t.Error("config color: never should apply when the flag is auto")
+	}
+}
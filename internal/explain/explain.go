@@ -0,0 +1,274 @@
+// Package explain reports why a path does or doesn't trigger each rule in
+// a config, pattern by pattern.
+package explain
+
+import (
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/action"
+	"github.com/devaloi/watchdog/internal/config"
+	"github.com/devaloi/watchdog/internal/display"
+	"github.com/devaloi/watchdog/internal/matcher"
+	"github.com/devaloi/watchdog/internal/rule"
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+// Content check outcomes in RuleReport.Content.
+const (
+	ContentNone       = ""
+	ContentMatched    = "matched"
+	ContentNoMatch    = "did not match"
+	ContentNotChecked = "not checked"
+	ContentInvalid    = "invalid"
+)
+
+// Report explains how an event is evaluated against a config.
+type Report struct {
+	Event   watcher.Event
+	Ignored bool
+	Ignores []matcher.Explanation
+	Rules   []RuleReport
+}
+
+// RuleReport explains one rule's decision.
+type RuleReport struct {
+	Name         string
+	Patterns     []matcher.Explanation
+	PatternMatch bool
+	Events       []string
+	EventMatch   bool
+	Content      string
+	Triggers     bool
+	Debounce     time.Duration
+	Action       config.Action
+	// Rendered is the command, log line or request the action would run.
+	Rendered  string
+	RenderErr error
+}
+
+// Explain evaluates ev against cfg. The trigger decision comes from
+// rule.Engine, so it always agrees with what watchdog would do; the
+// per-pattern explanations show why.
+func Explain(cfg *config.Config, ev watcher.Event) *Report {
+	var opts []matcher.Option
+	if cfg.Global.CaseInsensitive {
+		opts = append(opts, matcher.IgnoreCase())
+	}
+
+	r := &Report{Event: ev}
+
+	r.Ignored, r.Ignores = explainWatch(cfg.Global.Ignore, ev.Path, opts)
+
+	var triggered []string
+	for _, m := range rule.NewEngine(cfg).Evaluate(ev) {
+		triggered = append(triggered, m.RuleName)
+	}
+
+	for _, rl := range cfg.Rules {
+		rr := RuleReport{
+			Name:       rl.Name,
+			Events:     rl.Events,
+			EventMatch: eventMatch(rl.Events, ev.Type),
+			Triggers:   slices.Contains(triggered, rl.Name),
+			Debounce:   cfg.Global.Debounce.Duration,
+			Action:     rl.Action,
+		}
+
+		if rl.Debounce.Duration > 0 {
+			rr.Debounce = rl.Debounce.Duration
+		}
+
+		rr.PatternMatch, rr.Patterns = explainWatch(rl.Watch, ev.Path, opts)
+		rr.Content = explainContent(rl, ev.Path, !r.Ignored && rr.PatternMatch && rr.EventMatch)
+		rr.Rendered, rr.RenderErr = render(cfg, rl.Action, ev)
+
+		r.Rules = append(r.Rules, rr)
+	}
+
+	return r
+}
+
+// explainWatch reports whether any positive pattern matched and no
+// negated pattern excluded the path.
+func explainWatch(patterns []string, path string, opts []matcher.Option) (bool, []matcher.Explanation) {
+	included, excluded := false, false
+
+	out := make([]matcher.Explanation, 0, len(patterns))
+
+	for _, pattern := range patterns {
+		ex := matcher.ExplainPattern(pattern, path, opts...)
+		out = append(out, ex)
+
+		switch {
+		case ex.Negated && !ex.Matched:
+			excluded = true
+		case !ex.Negated && ex.Matched:
+			included = true
+		}
+	}
+
+	return included && !excluded, out
+}
+
+func explainContent(rl config.Rule, path string, check bool) string {
+	if rl.Contains == "" && rl.ContentRegex == "" {
+		return ContentNone
+	}
+
+	if !check {
+		return ContentNotChecked
+	}
+
+	p, err := rl.ParsedContent()
+	if err != nil {
+		return ContentInvalid
+	}
+
+	if p.Match(path) {
+		return ContentMatched
+	}
+
+	return ContentNoMatch
+}
+
+func render(cfg *config.Config, a config.Action, ev watcher.Event) (string, error) {
+	switch a.Type {
+	case "command":
+		cmd, err := cfg.ParsedCommand(a)
+		if err != nil {
+			return "", err
+		}
+
+		argv, err := cmd.Argv(ev, cfg.Vars)
+		if err != nil {
+			return "", err
+		}
+
+		if len(a.Args) == 0 {
+			// Show the script, not the shell invocation around it.
+			return argv[len(argv)-1], nil
+		}
+
+		words := make([]string, len(argv))
+		for i, w := range argv {
+			words[i] = action.ShellQuote(w)
+		}
+
+		return strings.Join(words, " "), nil
+	case "log":
+		format, err := a.ParsedFormat()
+		if err != nil {
+			return "", err
+		}
+
+		data := action.NewTemplateData(ev)
+		data.Vars = cfg.Vars
+
+		return format.Render(data)
+	case "webhook":
+		method := a.Method
+		if method == "" {
+			method = http.MethodPost
+		}
+
+		return method + " " + a.URL, nil
+	case "livereload":
+		addr := a.Addr
+		if addr == "" {
+			addr = action.DefaultLiveReloadAddr
+		}
+
+		kind := "page reload"
+		if action.IsStylesheet(ev.Path) {
+			kind = "CSS hot-swap"
+		}
+
+		return kind + " via " + addr, nil
+	default:
+		return "", nil
+	}
+}
+
+// Write prints the report for humans using the given glyphs.
+func (r *Report) Write(w io.Writer, g display.Glyphs) {
+	mark := func(ok bool) string {
+		if ok {
+			return g.Success
+		}
+
+		return g.Failure
+	}
+
+	write(w, r.Event.Path+" ("+string(r.Event.Type)+")\n")
+
+	if len(r.Ignores) > 0 {
+		write(w, "\nignore:\n")
+
+		for _, ex := range r.Ignores {
+			write(w, "  "+mark(ex.Matched)+" "+ex.Pattern+" "+g.Dash+" "+ex.Reason+"\n")
+		}
+
+		if r.Ignored {
+			write(w, "  path is ignored; no rule can trigger\n")
+		}
+	}
+
+	write(w, "\nrules:\n")
+
+	for _, rr := range r.Rules {
+		write(w, "  "+mark(rr.Triggers)+" "+rr.Name+" "+g.Dash+" "+rr.verdict(r.Ignored)+"\n")
+
+		for _, ex := range rr.Patterns {
+			write(w, "      "+mark(ex.Matched)+" "+ex.Pattern+" "+g.Dash+" "+ex.Reason+"\n")
+		}
+
+		if len(rr.Events) > 0 {
+			write(w, "      "+mark(rr.EventMatch)+" events: "+strings.Join(rr.Events, ", ")+"\n")
+		}
+
+		if rr.Content != ContentNone {
+			write(w, "      "+mark(rr.Content == ContentMatched)+" content: "+rr.Content+"\n")
+		}
+
+		write(w, "      debounce: "+rr.Debounce.String()+"\n")
+
+		if rr.RenderErr != nil {
+			write(w, "      action: "+rr.Action.Type+" (template error: "+rr.RenderErr.Error()+")\n")
+		} else {
+			write(w, "      action: "+rr.Action.Type+": "+rr.Rendered+"\n")
+		}
+	}
+}
+
+func (rr *RuleReport) verdict(ignored bool) string {
+	switch {
+	case rr.Triggers:
+		return "would trigger"
+	case ignored:
+		return "path is ignored"
+	case !rr.PatternMatch:
+		return "no watch pattern matched"
+	case !rr.EventMatch:
+		return "event type not in events"
+	default:
+		return "content " + rr.Content
+	}
+}
+
+func write(w io.Writer, s string) {
+	_, _ = io.WriteString(w, s)
+}
+
+// eventMatch mirrors the engine: a rule without an events list takes every
+// event type except the opt-in ones.
+func eventMatch(events []string, t watcher.EventType) bool {
+	if len(events) == 0 {
+		return !t.OptIn()
+	}
+
+	return slices.Contains(events, string(t))
+}
@@ -0,0 +1,158 @@
+package explain
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/config"
+	"github.com/devaloi/watchdog/internal/display"
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+func testConfig() *config.Config {
+	return &config.Config{
+		Global: config.Global{
+			Debounce: config.Duration{Duration: 500 * time.Millisecond},
+			Ignore:   []string{".git", "node_modules"},
+		},
+		Rules: []config.Rule{
+			{
+				Name:   "Go rebuild",
+				Watch:  []string{"**/*.go", "!**/*_test.go"},
+				Events: []string{"create", "modify"},
+				Action: config.Action{Type: "command", Command: "go build ./{{.Dir}}"},
+			},
+			{
+				Name:     "CSS reload",
+				Watch:    []string{"assets/**/*.css"},
+				Debounce: config.Duration{Duration: time.Second},
+				Action:   config.Action{Type: "webhook", URL: "http://localhost:3000/reload"},
+			},
+		},
+	}
+}
+
+func TestExplainTriggers(t *testing.T) {
+	ev := watcher.Event{Path: "cmd/app/main.go", Type: watcher.Modify, Name: "main.go", Dir: "cmd/app"}
+	r := Explain(testConfig(), ev)
+
+	if r.Ignored {
+		t.Fatal("path should not be ignored")
+	}
+
+	build := r.Rules[0]
+	if !build.Triggers || !build.PatternMatch || !build.EventMatch {
+		t.Errorf("Go rebuild = %+v, want trigger", build)
+	}
+
+	if build.Rendered != "go build ./cmd/app" || build.Debounce != 500*time.Millisecond {
+		t.Errorf("rendered = %q, debounce = %v", build.Rendered, build.Debounce)
+	}
+
+	css := r.Rules[1]
+	if css.Triggers || css.PatternMatch {
+		t.Errorf("CSS reload = %+v, want no match", css)
+	}
+
+	if css.Debounce != time.Second || css.Rendered != "POST http://localhost:3000/reload" {
+		t.Errorf("rendered = %q, debounce = %v", css.Rendered, css.Debounce)
+	}
+
+	if !strings.Contains(css.Patterns[0].Reason, `"assets" did not match path segment "cmd"`) {
+		t.Errorf("reason = %q", css.Patterns[0].Reason)
+	}
+}
+
+func TestExplainRendersQuotedCommand(t *testing.T) {
+	cfg := testConfig()
+	cfg.Rules = append(cfg.Rules, config.Rule{
+		Name:   "Go vet",
+		Watch:  []string{"**/*.go"},
+		Action: config.Action{Type: "command", Args: []string{"go", "vet", "{{.Path}}"}},
+	})
+
+	ev := watcher.Event{Path: "my app/$(id).go", Type: watcher.Modify, Name: "$(id).go", Dir: "my app"}
+	r := Explain(cfg, ev)
+
+	if got := r.Rules[0].Rendered; got != "go build ./'my app'" {
+		t.Errorf("command = %q", got)
+	}
+
+	if got := r.Rules[2].Rendered; got != "go vet 'my app/$(id).go'" {
+		t.Errorf("args = %q", got)
+	}
+}
+
+func TestExplainFiltered(t *testing.T) {
+	tests := []struct {
+		name    string
+		ev      watcher.Event
+		verdict string
+	}{
+		{"negated", watcher.Event{Path: "pkg/a_test.go", Type: watcher.Modify}, "no watch pattern matched"},
+		{"event", watcher.Event{Path: "main.go", Type: watcher.Delete}, "event type not in events"},
+		{"ignored", watcher.Event{Path: ".git/hooks/x.go", Type: watcher.Modify}, "path is ignored"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Explain(testConfig(), tt.ev)
+
+			build := r.Rules[0]
+			if build.Triggers {
+				t.Fatal("Go rebuild should not trigger")
+			}
+
+			if got := build.verdict(r.Ignored); got != tt.verdict {
+				t.Errorf("verdict = %q, want %q", got, tt.verdict)
+			}
+		})
+	}
+}
+
+func TestExplainNegatedIgnore(t *testing.T) {
+	cfg := &config.Config{
+		Global: config.Global{Ignore: []string{"*.log", "!keep.log"}},
+		Rules: []config.Rule{
+			{Name: "all", Watch: []string{"**"}, Action: config.Action{Type: "log", Format: "{{.Path}}"}},
+		},
+	}
+
+	for path, ignored := range map[string]bool{"a.go": false, "keep.log": false, "debug.log": true} {
+		r := Explain(cfg, watcher.Event{Path: path, Type: watcher.Modify, Name: path, Dir: "."})
+
+		if r.Ignored != ignored {
+			t.Errorf("%s: ignored = %v, want %v", path, r.Ignored, ignored)
+		}
+
+		if r.Rules[0].Triggers == r.Ignored {
+			t.Errorf("%s: triggers = %v contradicts ignored = %v", path, r.Rules[0].Triggers, r.Ignored)
+		}
+	}
+}
+
+func TestReportWrite(t *testing.T) {
+	var buf bytes.Buffer
+
+	Explain(testConfig(), watcher.Event{Path: "main.go", Type: watcher.Modify, Dir: "."}).Write(&buf, display.ASCIIGlyphs)
+
+	out := buf.String()
+
+	for _, want := range []string{
+		"main.go (modify)",
+		`FAIL .git - no path segment equals ".git"`,
+		"OK Go rebuild - would trigger",
+		"OK **/*.go - every path segment matched",
+		"OK events: create, modify",
+		"debounce: 500ms",
+		"action: command: go build ./.",
+		"FAIL CSS reload - no watch pattern matched",
+		"debounce: 1s",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+}
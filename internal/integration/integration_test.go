@@ -37,6 +37,17 @@ func (w *syncWriter) String() string {
 
 var _ io.Writer = (*syncWriter)(nil)
 
+func mustTemplate(t *testing.T, src string) *action.Template {
+	t.Helper()
+
+	tmpl, err := action.ParseTemplate(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tmpl
+}
+
 func TestWatcherToRuleToAction(t *testing.T) {
 	dir := t.TempDir()
 
@@ -58,7 +69,7 @@ func TestWatcherToRuleToAction(t *testing.T) {
 	eng := rule.NewEngine(cfg)
 
 	logBuf := &syncWriter{}
-	logAction := action.NewLogAction("{{.Event}} {{.Name}}", logBuf)
+	logAction := action.NewLogAction(mustTemplate(t, "{{.Event}} {{.Name}}"), logBuf)
 
 	w, err := watcher.New(dir)
 	if err != nil {
@@ -73,7 +84,7 @@ func TestWatcherToRuleToAction(t *testing.T) {
 	done := make(chan struct{})
 
 	go func() {
-		for ev := range w.Events {
+		for ev := range w.Events() {
 			relPath, relErr := filepath.Rel(dir, ev.Path)
 			if relErr == nil {
 				ev.Path = relPath
@@ -133,7 +144,7 @@ func TestWatcherIgnoresFilteredPaths(t *testing.T) {
 	eng := rule.NewEngine(cfg)
 
 	logBuf := &syncWriter{}
-	logAction := action.NewLogAction("{{.Name}}", logBuf)
+	logAction := action.NewLogAction(mustTemplate(t, "{{.Name}}"), logBuf)
 
 	w, err := watcher.New(dir)
 	if err != nil {
@@ -146,7 +157,7 @@ func TestWatcherIgnoresFilteredPaths(t *testing.T) {
 	defer deb.Stop()
 
 	go func() {
-		for ev := range w.Events {
+		for ev := range w.Events() {
 			relPath, relErr := filepath.Rel(dir, ev.Path)
 			if relErr == nil {
 				ev.Path = relPath
@@ -207,8 +218,8 @@ func TestMultipleRulesMatchSameEvent(t *testing.T) {
 
 	logBuf := &syncWriter{}
 
-	actionA := action.NewLogAction("A:{{.Name}}", logBuf)
-	actionB := action.NewLogAction("B:{{.Name}}", logBuf)
+	actionA := action.NewLogAction(mustTemplate(t, "A:{{.Name}}"), logBuf)
+	actionB := action.NewLogAction(mustTemplate(t, "B:{{.Name}}"), logBuf)
 
 	actions := map[string]action.Action{
 		"Rule A": actionA,
@@ -226,7 +237,7 @@ func TestMultipleRulesMatchSameEvent(t *testing.T) {
 	defer deb.Stop()
 
 	go func() {
-		for ev := range w.Events {
+		for ev := range w.Events() {
 			relPath, relErr := filepath.Rel(dir, ev.Path)
 			if relErr == nil {
 				ev.Path = relPath
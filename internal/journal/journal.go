@@ -0,0 +1,139 @@
+// Package journal records raw watcher events and the engine's decisions
+// to an append-only JSONL file, and replays them through a config.
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/rule"
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+// maxLineSize bounds a single journal line when reading.
+const maxLineSize = 1024 * 1024
+
+// Entry is one line of the journal: a raw event, when it arrived and which
+// rules matched it. An empty Matches means the event was filtered.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Path    string    `json:"path"`
+	Event   string    `json:"event"`
+	Name    string    `json:"name,omitempty"`
+	Dir     string    `json:"dir,omitempty"`
+	Matches []string  `json:"matches"`
+}
+
+// WatcherEvent converts the entry back into the event it recorded.
+func (e Entry) WatcherEvent() watcher.Event {
+	return watcher.Event{
+		Path: e.Path,
+		Type: watcher.EventType(e.Event),
+		Name: e.Name,
+		Dir:  e.Dir,
+	}
+}
+
+// Writer appends entries to a journal file. It is safe for concurrent use.
+type Writer struct {
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+	now func() time.Time
+}
+
+// Create opens the journal at path for appending, creating it if needed.
+func Create(path string) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600) //nolint:gosec // journal path is user-configured
+	if err != nil {
+		return nil, err
+	}
+
+	return &Writer{f: f, enc: json.NewEncoder(f), now: time.Now}, nil
+}
+
+// Record appends an event and the rules that matched it.
+func (w *Writer) Record(ev watcher.Event, matches []rule.Match) error {
+	names := make([]string, 0, len(matches))
+	for _, m := range matches {
+		names = append(names, m.RuleName)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.enc.Encode(Entry{
+		Time:    w.now().UTC(),
+		Path:    ev.Path,
+		Event:   string(ev.Type),
+		Name:    ev.Name,
+		Dir:     ev.Dir,
+		Matches: names,
+	})
+}
+
+// Close closes the journal file.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.f.Close()
+}
+
+// Read parses every entry from a journal. A truncated last line, as left
+// by a crash mid-write, is ignored.
+func Read(r io.Reader) ([]Entry, error) {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	var (
+		entries []Entry
+		pending error
+	)
+
+	for line := 1; sc.Scan(); line++ {
+		if pending != nil {
+			return nil, pending
+		}
+
+		if len(sc.Bytes()) == 0 {
+			continue
+		}
+
+		var e Entry
+
+		err := json.Unmarshal(sc.Bytes(), &e)
+		if err != nil {
+			pending = errors.New("journal: line " + strconv.Itoa(line) + ": " + err.Error())
+
+			continue
+		}
+
+		entries = append(entries, e)
+	}
+
+	err := sc.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// ReadFile reads a journal file.
+func ReadFile(path string) ([]Entry, error) {
+	f, err := os.Open(path) //nolint:gosec // journal path is user-supplied by design
+	if err != nil {
+		return nil, err
+	}
+
+	defer func() { _ = f.Close() }()
+
+	return Read(f)
+}
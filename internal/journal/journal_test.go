@@ -0,0 +1,217 @@
+package journal
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/config"
+	"github.com/devaloi/watchdog/internal/rule"
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+func testConfig(goWatch string) *config.Config {
+	return &config.Config{
+		Global: config.Global{Ignore: []string{".git"}},
+		Rules: []config.Rule{
+			{Name: "build", Watch: []string{goWatch}, Action: config.Action{Type: "log", Format: "x"}},
+			{Name: "css", Watch: []string{"**/*.css"}, Action: config.Action{Type: "log", Format: "x"}},
+		},
+	}
+}
+
+func TestWriteAndRead(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+
+	w, err := Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { return start }
+
+	engine := rule.NewEngine(testConfig("**/*.go"))
+
+	for _, ev := range []watcher.Event{
+		{Path: "main.go", Type: watcher.Modify, Name: "main.go", Dir: "."},
+		{Path: ".git/index", Type: watcher.Modify, Name: "index", Dir: ".git"},
+	} {
+		err = w.Record(ev, engine.Evaluate(ev))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("got %d entries, want 2", len(entries))
+	}
+
+	e := entries[0]
+	if e.Path != "main.go" || e.Event != "modify" || !e.Time.Equal(start) || len(e.Matches) != 1 || e.Matches[0] != "build" {
+		t.Errorf("entry = %+v", e)
+	}
+
+	if entries[1].Matches == nil || len(entries[1].Matches) != 0 {
+		t.Errorf("filtered entry matches = %#v, want empty", entries[1].Matches)
+	}
+
+	if ev := e.WatcherEvent(); ev.Type != watcher.Modify || ev.Dir != "." {
+		t.Errorf("WatcherEvent = %+v", ev)
+	}
+}
+
+func TestReadTruncated(t *testing.T) {
+	input := `{"time":"2025-01-01T12:00:00Z","path":"a.go","event":"modify","matches":[]}
+{"time":"2025-01-01T12:00:01Z","path":"b.go","ev`
+
+	entries, err := Read(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Errorf("got %d entries, want 1", len(entries))
+	}
+
+	_, err = Read(strings.NewReader("not json\n" + input))
+	if err == nil {
+		t.Error("expected error for a corrupt line before the end")
+	}
+}
+
+func TestReplay(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	entries := []Entry{
+		{Time: start, Path: "main.go", Event: "modify", Matches: []string{"build"}},
+		{Time: start.Add(2 * time.Second), Path: "cmd/app.go", Event: "create", Matches: []string{"build"}},
+		{Time: start.Add(time.Minute), Path: "site.css", Event: "modify", Matches: []string{"css"}},
+	}
+
+	// The new config only watches the top level, so cmd/app.go stops matching.
+	cfg := testConfig("*.go")
+
+	var (
+		waits     []time.Duration
+		decisions []Decision
+		fires     []Fire
+	)
+
+	wait := func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+
+		return nil
+	}
+
+	opts := ReplayOptions{Speed: 2, MaxGap: 10 * time.Second}
+
+	err := replay(context.Background(), entries, cfg, opts, func(d Decision) {
+		decisions = append(decisions, d)
+	}, func(f Fire) {
+		fires = append(fires, f)
+	}, wait)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(waits) != 2 || waits[0] != time.Second || waits[1] != 10*time.Second {
+		t.Errorf("waits = %v, want [1s 10s]", waits)
+	}
+
+	changed := []bool{false, true, false}
+	for i, d := range decisions {
+		if d.Changed != changed[i] {
+			t.Errorf("%s: changed = %v, want %v", d.Entry.Path, d.Changed, changed[i])
+		}
+	}
+
+	want := []Fire{
+		{Elapsed: 0, RuleName: "build", Event: entries[0].WatcherEvent()},
+		{Elapsed: 11 * time.Second, RuleName: "css", Event: entries[2].WatcherEvent()},
+	}
+	if !slices.Equal(fires, want) {
+		t.Errorf("fires = %+v, want %+v", fires, want)
+	}
+
+	waits = nil
+
+	err = replay(context.Background(), entries, cfg, ReplayOptions{}, func(Decision) {}, func(Fire) {}, wait)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(waits) != 0 {
+		t.Errorf("speed 0 waited %v", waits)
+	}
+}
+
+func TestReplayTiming(t *testing.T) {
+	start := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	var entries []Entry
+	for i := range 3 {
+		entries = append(entries, Entry{Time: start.Add(time.Duration(i) * time.Second), Path: "main.go", Event: "modify"})
+	}
+
+	entries = append(entries, Entry{Time: start.Add(time.Minute), Path: "upload.css", Event: "create"})
+
+	cfg := testConfig("*.go")
+	cfg.Rules[0].Debounce.Duration = 500 * time.Millisecond
+	cfg.Rules[1].StableFor.Duration = 2 * time.Second
+
+	tests := []struct {
+		name string
+		opts ReplayOptions
+		want []time.Duration
+	}{
+		// Recorded gaps outlast the debounce, so each modify fires.
+		{"recorded", ReplayOptions{}, []time.Duration{500 * time.Millisecond, 1500 * time.Millisecond, 2500 * time.Millisecond, 62 * time.Second}},
+		// Ten times faster, the modifies fall within one debounce.
+		{"speed", ReplayOptions{Speed: 10}, []time.Duration{700 * time.Millisecond, 8 * time.Second}},
+		// Capped gaps coalesce the modifies and shorten the idle period.
+		{"max gap", ReplayOptions{MaxGap: 100 * time.Millisecond}, []time.Duration{700 * time.Millisecond, 2300 * time.Millisecond}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []time.Duration
+
+			err := replay(context.Background(), entries, cfg, tt.opts, func(Decision) {}, func(f Fire) {
+				got = append(got, f.Elapsed)
+			}, func(context.Context, time.Duration) error { return nil })
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("fires at %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	entries := []Entry{{Path: "main.go", Event: "modify"}}
+	calls := 0
+
+	err := Replay(ctx, entries, testConfig("*.go"), ReplayOptions{}, func(Decision) { calls++ }, func(Fire) { calls++ })
+	if err == nil || calls != 0 {
+		t.Errorf("err = %v, calls = %d; want canceled before any event", err, calls)
+	}
+}
@@ -0,0 +1,155 @@
+package journal
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/config"
+	"github.com/devaloi/watchdog/internal/dispatch"
+	"github.com/devaloi/watchdog/internal/rule"
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+// settleStep is how far the replay clock moves at a time after the last
+// entry, while debounce and stable_for waits finish.
+const settleStep = time.Second
+
+// Decision is the outcome of replaying one entry through the rules.
+type Decision struct {
+	Entry   Entry
+	Matches []rule.Match
+	// Changed is set when the rules matched now differ from the ones
+	// recorded in the journal.
+	Changed bool
+}
+
+// Fire is a rule action that would have run during a replay, after
+// debouncing and any stable_for wait.
+type Fire struct {
+	// Elapsed is the replay time since the first entry.
+	Elapsed  time.Duration
+	RuleName string
+	Event    watcher.Event
+}
+
+// ReplayOptions control replay timing.
+type ReplayOptions struct {
+	// Speed scales the original gaps between events: 1 keeps the recorded
+	// timing, 10 replays ten times faster and 0 replays the recorded
+	// timing without waiting.
+	Speed float64
+	// MaxGap caps a single gap, so long idle periods don't stall replay.
+	// Zero means no cap.
+	MaxGap time.Duration
+}
+
+// Replay feeds entries through a dispatcher for cfg in order and calls
+// decide with each entry's matches and fire with each action that would
+// run. The dispatcher's debounce and stable_for timers see the gaps as
+// opts compress them, so a faster replay can coalesce events that fired
+// separately. It stops early if ctx is canceled.
+func Replay(ctx context.Context, entries []Entry, cfg *config.Config, opts ReplayOptions, decide func(Decision), fire func(Fire)) error {
+	return replay(ctx, entries, cfg, opts, decide, fire, sleep)
+}
+
+func replay(ctx context.Context, entries []Entry, cfg *config.Config, opts ReplayOptions, decide func(Decision), fire func(Fire), wait func(context.Context, time.Duration) error) error {
+	if len(entries) == 0 {
+		return ctx.Err()
+	}
+
+	start := entries[0].Time
+	clock := watcher.NewManualClock(start)
+
+	d := dispatch.New(cfg, clock, func(m rule.Match, ev watcher.Event) {
+		fire(Fire{Elapsed: clock.Now().Sub(start), RuleName: m.RuleName, Event: ev})
+	})
+	defer d.Stop()
+
+	var (
+		matches   []rule.Match
+		observing bool
+	)
+
+	d.Observe = func(_ watcher.Event, m []rule.Match) {
+		if observing {
+			matches = m
+		}
+	}
+
+	for i, e := range entries {
+		if i > 0 {
+			gap := max(e.Time.Sub(entries[i-1].Time), 0)
+			if opts.Speed > 0 {
+				gap = time.Duration(float64(gap) / opts.Speed)
+			}
+
+			if opts.MaxGap > 0 {
+				gap = min(gap, opts.MaxGap)
+			}
+
+			if opts.Speed > 0 && gap > 0 {
+				err := wait(ctx, gap)
+				if err != nil {
+					return err
+				}
+			}
+
+			clock.Advance(gap)
+		}
+
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+
+		observing = true
+		d.Dispatch(e.WatcherEvent())
+		observing = false
+
+		decide(Decision{
+			Entry:   e,
+			Matches: matches,
+			Changed: !sameRules(e.Matches, matches),
+		})
+	}
+
+	// Let pending debounce and stable_for waits run out. Every wait is
+	// bounded, so the clock empties.
+	for clock.Pending() > 0 {
+		err := ctx.Err()
+		if err != nil {
+			return err
+		}
+
+		clock.Advance(settleStep)
+	}
+
+	return nil
+}
+
+func sameRules(recorded []string, matches []rule.Match) bool {
+	if len(recorded) != len(matches) {
+		return false
+	}
+
+	for _, m := range matches {
+		if !slices.Contains(recorded, m.RuleName) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,34 @@
+package matcher
+
+import "sync"
+
+// maxCacheEntries bounds each compile cache so ad-hoc patterns passed to
+// MatchPattern cannot grow memory without limit.
+const maxCacheEntries = 4096
+
+// cache is a concurrency-safe map from pattern source to its compiled form.
+// It is cleared when full; configured patterns are recompiled on demand.
+type cache[T any] struct {
+	mu sync.RWMutex
+	m  map[string]T
+}
+
+func (c *cache[T]) load(key string) (T, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	v, ok := c.m[key]
+
+	return v, ok
+}
+
+func (c *cache[T]) store(key string, v T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.m == nil || len(c.m) >= maxCacheEntries {
+		c.m = make(map[string]T)
+	}
+
+	c.m[key] = v
+}
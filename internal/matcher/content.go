@@ -0,0 +1,72 @@
+package matcher
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"regexp"
+)
+
+// DefaultContentLimit is the number of leading bytes read from a file
+// when evaluating a ContentPredicate.
+const DefaultContentLimit = 64 * 1024
+
+// ContentPredicate matches files by their contents. Only the first Limit
+// bytes are inspected so large or binary files stay cheap to evaluate.
+type ContentPredicate struct {
+	contains []byte
+	re       *regexp.Regexp
+	Limit    int64
+}
+
+// NewContentPredicate creates a predicate requiring the file prefix to contain
+// the literal substring and match the regular expression. Empty arguments are skipped.
+func NewContentPredicate(contains, expr string) (*ContentPredicate, error) {
+	p := &ContentPredicate{Limit: DefaultContentLimit}
+
+	if contains != "" {
+		p.contains = []byte(contains)
+	}
+
+	if expr != "" {
+		re, err := compileRegex(expr)
+		if err != nil {
+			return nil, err
+		}
+
+		p.re = re
+	}
+
+	return p, nil
+}
+
+// Match reads the leading bytes of the file at path and reports whether
+// they satisfy the predicate. Unreadable files never match.
+func (p *ContentPredicate) Match(path string) bool {
+	f, err := os.Open(path) //nolint:gosec // path comes from watched file events
+	if err != nil {
+		return false
+	}
+
+	defer func() { _ = f.Close() }()
+
+	data, err := io.ReadAll(io.LimitReader(f, p.Limit))
+	if err != nil {
+		return false
+	}
+
+	return p.MatchBytes(data)
+}
+
+// MatchBytes reports whether data satisfies the predicate.
+func (p *ContentPredicate) MatchBytes(data []byte) bool {
+	if p.contains != nil && !bytes.Contains(data, p.contains) {
+		return false
+	}
+
+	if p.re != nil && !p.re.Match(data) {
+		return false
+	}
+
+	return true
+}
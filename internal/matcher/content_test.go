@@ -0,0 +1,85 @@
+package matcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestContentPredicateMatchBytes(t *testing.T) {
+	tests := []struct {
+		name     string
+		contains string
+		expr     string
+		data     string
+		want     bool
+	}{
+		{"contains match", "draft: true", "", "---\ndraft: true\n---\n", true},
+		{"contains no match", "draft: true", "", "---\ndraft: false\n---\n", false},
+		{"regex match", "", `(?m)^package main$`, "// doc\npackage main\n", true},
+		{"regex no match", "", `(?m)^package main$`, "package lib\n", false},
+		{"both required", "TODO", `func \w+`, "func main() {}\n", false},
+		{"both match", "TODO", `func \w+`, "// TODO\nfunc main() {}\n", true},
+		{"empty predicate", "", "", "anything", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewContentPredicate(tt.contains, tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := p.MatchBytes([]byte(tt.data))
+			if got != tt.want {
+				t.Errorf("MatchBytes(%q) = %v, want %v", tt.data, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentPredicateReadsBoundedPrefix(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "big.md")
+
+	data := strings.Repeat("x", 100) + "draft: true"
+
+	writeErr := os.WriteFile(path, []byte(data), 0o600)
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+
+	p, err := NewContentPredicate("draft: true", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !p.Match(path) {
+		t.Error("expected match with default limit")
+	}
+
+	p.Limit = 100
+
+	if p.Match(path) {
+		t.Error("expected no match beyond the read limit")
+	}
+}
+
+func TestContentPredicateMissingFile(t *testing.T) {
+	p, err := NewContentPredicate("x", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Match(filepath.Join(t.TempDir(), "missing.txt")) {
+		t.Error("missing file should not match")
+	}
+}
+
+func TestContentPredicateInvalidRegex(t *testing.T) {
+	_, err := NewContentPredicate("", "([")
+	if err == nil {
+		t.Fatal("expected error for invalid regex")
+	}
+}
@@ -0,0 +1,205 @@
+package matcher
+
+import (
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Explanation describes how one pattern was evaluated against a path.
+type Explanation struct {
+	Pattern string
+	// Matched is the pattern's result, after negation.
+	Matched bool
+	Negated bool
+	Reason  string
+}
+
+// segmentFailure is where matchSegments gave up: the pattern and path
+// segment indices it was comparing. pat is -1 when the pattern ran out
+// and name is the path length when the path ran out.
+type segmentFailure struct {
+	pat  int
+	name int
+}
+
+// ExplainPattern evaluates pattern against path like MatchPattern and
+// describes why it matched or, for globs, which segment failed. With the
+// IgnoreCase option the pattern is matched as a Set built with it would.
+func ExplainPattern(pattern, path string, opts ...Option) Explanation {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	ex := Explanation{Pattern: pattern, Negated: IsNegated(pattern)}
+	src := strings.TrimPrefix(pattern, NegatePrefix)
+	path = normalize(filepath.ToSlash(path))
+
+	if expr, ok := strings.CutPrefix(src, RegexPrefix); ok {
+		if o.ignoreCase {
+			expr = IgnoreCasePrefix + expr
+		}
+
+		re, err := compileRegex(expr)
+		if err != nil {
+			ex.Reason = "invalid regex: " + err.Error()
+
+			return ex
+		}
+
+		hit := re.MatchString(path)
+		ex.Matched = hit != ex.Negated
+		ex.Reason = "regex " + verb(hit) + " " + strconv.Quote(path)
+
+		return ex
+	}
+
+	if _, ok := cutIgnoreCase(src); !ok && o.ignoreCase {
+		src = IgnoreCasePrefix + src
+	}
+
+	cp, err := compilePattern(src)
+	if err != nil {
+		ex.Reason = "invalid pattern: " + err.Error()
+
+		return ex
+	}
+
+	hit, reason := cp.explain(path)
+	ex.Matched = hit != ex.Negated
+	ex.Reason = reason
+
+	if ex.Negated {
+		ex.Reason += "; negated"
+	}
+
+	return ex
+}
+
+func verb(hit bool) string {
+	if hit {
+		return "matched"
+	}
+
+	return "did not match"
+}
+
+// explain is match with a description of the outcome. When no alternative
+// matches it reports the one that got furthest into the path.
+func (cp *compiledPattern) explain(path string) (bool, string) {
+	if cp.fold {
+		path = fold(path)
+	}
+
+	parts := strings.Split(path, "/")
+
+	for _, name := range cp.bare {
+		for _, part := range parts {
+			if part == name {
+				return true, "path segment " + strconv.Quote(part) + " equals bare name"
+			}
+		}
+	}
+
+	var (
+		best     segmentFailure
+		bestSegs []*segment
+	)
+
+	for _, segs := range cp.globs {
+		ok, f := explainSegments(segs, parts, 0, 0)
+		if ok {
+			return true, "every path segment matched"
+		}
+
+		if bestSegs == nil || f.rank(len(parts)) > best.rank(len(parts)) {
+			best, bestSegs = f, segs
+		}
+	}
+
+	if bestSegs == nil {
+		return false, "no path segment equals " + quoteAll(cp.bare)
+	}
+
+	return false, describeFailure(bestSegs, parts, best)
+}
+
+// explainSegments mirrors matchSegments, additionally returning the
+// furthest point reached when the match fails.
+func explainSegments(patParts []*segment, nameParts []string, pOff, nOff int) (bool, segmentFailure) {
+	pi, ni := 0, 0
+
+	for pi < len(patParts) && ni < len(nameParts) {
+		if patParts[pi].kind == segAnyDepth {
+			total := nOff + len(nameParts)
+
+			var best segmentFailure
+
+			for skip := ni; skip <= len(nameParts); skip++ {
+				ok, f := explainSegments(patParts[pi+1:], nameParts[skip:], pOff+pi+1, nOff+skip)
+				if ok {
+					return true, f
+				}
+
+				if skip == ni || f.rank(total) > best.rank(total) {
+					best = f
+				}
+			}
+
+			return false, best
+		}
+
+		if !patParts[pi].matchName(nameParts[ni]) {
+			return false, segmentFailure{pat: pOff + pi, name: nOff + ni}
+		}
+
+		pi++
+		ni++
+	}
+
+	for pi < len(patParts) && patParts[pi].kind == segAnyDepth {
+		pi++
+	}
+
+	switch {
+	case pi == len(patParts) && ni == len(nameParts):
+		return true, segmentFailure{}
+	case pi < len(patParts):
+		// The path ran out; report it as failing at its end.
+		return false, segmentFailure{pat: pOff + pi, name: nOff + ni}
+	default:
+		return false, segmentFailure{pat: -1, name: nOff + ni}
+	}
+}
+
+// rank orders failures by how far into the path they got. Running out of
+// path ranks lowest, since a concrete segment mismatch explains more.
+func (f segmentFailure) rank(total int) int {
+	if f.pat >= 0 && f.name >= total {
+		return -1
+	}
+
+	return f.name
+}
+
+func describeFailure(segs []*segment, parts []string, f segmentFailure) string {
+	switch {
+	case f.pat < 0:
+		return "path has extra segments " + strconv.Quote(strings.Join(parts[f.name:], "/")) + " after the pattern ended"
+	case f.name >= len(parts):
+		return "path ended before pattern segment " + strconv.Itoa(f.pat+1) + " " + strconv.Quote(segs[f.pat].source)
+	default:
+		return "pattern segment " + strconv.Itoa(f.pat+1) + " " + strconv.Quote(segs[f.pat].source) +
+			" did not match path segment " + strconv.Quote(parts[f.name])
+	}
+}
+
+func quoteAll(names []string) string {
+	quoted := make([]string, 0, len(names))
+	for _, n := range names {
+		quoted = append(quoted, strconv.Quote(n))
+	}
+
+	return strings.Join(quoted, " or ")
+}
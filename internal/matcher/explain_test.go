@@ -0,0 +1,58 @@
+package matcher
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExplainPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		matched bool
+		reason  string
+	}{
+		{"**/*.go", "cmd/app/main.go", true, "every path segment matched"},
+		{"**/*.go", "cmd/app/main.rs", false, `pattern segment 2 "*.go" did not match path segment "main.rs"`},
+		{"src/**/*.ts", "lib/a.ts", false, `pattern segment 1 "src" did not match path segment "lib"`},
+		{"src/*.ts", "src/a/b.ts", false, `pattern segment 2 "*.ts" did not match path segment "a"`},
+		{"src/*/b.ts", "src/a", false, `path ended before pattern segment 3 "b.ts"`},
+		{"src/*", "src/a/b", false, `path has extra segments "b" after the pattern ended`},
+		{"node_modules", "web/node_modules/x.js", true, `path segment "node_modules" equals bare name`},
+		{".git", "src/main.go", false, `no path segment equals ".git"`},
+		{"!**/*_test.go", "pkg/a_test.go", false, "every path segment matched; negated"},
+		{"re:^docs/", "docs/a.md", true, `regex matched "docs/a.md"`},
+		{"re:^docs/", "src/a.md", false, `regex did not match "src/a.md"`},
+		{"[", "a", false, "invalid pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			ex := ExplainPattern(tt.pattern, tt.path)
+
+			if ex.Matched != tt.matched {
+				t.Errorf("Matched = %v, want %v (%s)", ex.Matched, tt.matched, ex.Reason)
+			}
+
+			if !strings.HasPrefix(ex.Reason, tt.reason) {
+				t.Errorf("Reason = %q, want prefix %q", ex.Reason, tt.reason)
+			}
+
+			if want := MatchPattern(tt.pattern, tt.path); ex.Matched != want {
+				t.Errorf("Matched = %v disagrees with MatchPattern = %v", ex.Matched, want)
+			}
+		})
+	}
+}
+
+func TestExplainPatternIgnoreCase(t *testing.T) {
+	ex := ExplainPattern("**/*.JPG", "photos/a.jpg", IgnoreCase())
+	if !ex.Matched {
+		t.Errorf("expected case-insensitive match: %s", ex.Reason)
+	}
+
+	ex = ExplainPattern("re:\\.JPG$", "a.jpg", IgnoreCase())
+	if !ex.Matched {
+		t.Errorf("expected case-insensitive regex match: %s", ex.Reason)
+	}
+}
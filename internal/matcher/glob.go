@@ -0,0 +1,598 @@
+package matcher
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxBraceExpansions bounds how many alternatives a single pattern may expand to.
+const maxBraceExpansions = 1024
+
+// NegatePrefix marks a pattern in a list as an exclusion, e.g. "!**/*_test.go".
+const NegatePrefix = "!"
+
+var (
+	errBadPattern      = errors.New("syntax error in pattern")
+	errTooManyBraces   = errors.New("pattern expands to too many alternatives")
+	errEmptyExpression = errors.New("empty pattern")
+)
+
+// patternCache holds compiled patterns keyed by source text.
+var patternCache cache[*compiledPattern]
+
+// compiledPattern is the parsed form of a single pattern. A glob pattern may
+// expand (via braces) into several bare names and segment lists.
+type compiledPattern struct {
+	negate bool
+	fold   bool
+	bare   []string
+	globs  [][]*segment
+}
+
+func compilePattern(pattern string) (*compiledPattern, error) {
+	if cp, ok := patternCache.load(pattern); ok {
+		return cp, nil
+	}
+
+	cp := &compiledPattern{}
+
+	src := filepath.ToSlash(pattern)
+	if rest, ok := strings.CutPrefix(src, NegatePrefix); ok {
+		cp.negate = true
+		src = rest
+	}
+
+	if rest, ok := cutIgnoreCase(src); ok {
+		cp.fold = true
+		src = fold(normalize(rest))
+	} else {
+		src = normalize(src)
+	}
+
+	if src == "" {
+		return nil, errEmptyExpression
+	}
+
+	expanded, err := expandBraces(src)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, alt := range expanded {
+		parts := strings.Split(alt, "/")
+		segs := make([]*segment, 0, len(parts))
+
+		for _, part := range parts {
+			seg, segErr := compileSegment(part)
+			if segErr != nil {
+				return nil, segErr
+			}
+
+			segs = append(segs, seg)
+		}
+
+		// A single literal segment is a bare name matching any path segment.
+		if len(segs) == 1 && segs[0].kind == segLiteral {
+			cp.bare = append(cp.bare, segs[0].literal)
+
+			continue
+		}
+
+		cp.globs = append(cp.globs, segs)
+	}
+
+	patternCache.store(pattern, cp)
+
+	return cp, nil
+}
+
+// match reports whether the normalized, slash-separated path matches,
+// ignoring negation.
+func (cp *compiledPattern) match(path string) bool {
+	if cp.fold {
+		path = fold(path)
+	}
+
+	parts := strings.Split(path, "/")
+
+	for _, name := range cp.bare {
+		for _, part := range parts {
+			if part == name {
+				return true
+			}
+		}
+	}
+
+	for _, segs := range cp.globs {
+		if matchSegments(segs, parts) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// expandBraces expands every {a,b} group in the pattern, including nested
+// groups and groups spanning "/". Escaped braces and unmatched braces are
+// kept literally.
+func expandBraces(pattern string) ([]string, error) {
+	out := []string{}
+
+	err := expandInto(pattern, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+func expandInto(pattern string, out *[]string) error {
+	open, closeIdx, commas := findBraces(pattern)
+	if open < 0 {
+		if len(*out) >= maxBraceExpansions {
+			return errTooManyBraces
+		}
+
+		*out = append(*out, pattern)
+
+		return nil
+	}
+
+	prefix := pattern[:open]
+	suffix := pattern[closeIdx+1:]
+	start := open + 1
+
+	for _, end := range append(commas, closeIdx) {
+		err := expandInto(prefix+pattern[start:end]+suffix, out)
+		if err != nil {
+			return err
+		}
+
+		start = end + 1
+	}
+
+	return nil
+}
+
+// findBraces locates the first unescaped "{" with a matching "}" and the
+// top-level commas between them. It returns open == -1 if there is none.
+func findBraces(pattern string) (int, int, []int) {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			closeIdx, commas := matchBrace(pattern, i)
+			if closeIdx >= 0 {
+				return i, closeIdx, commas
+			}
+		}
+	}
+
+	return -1, -1, nil
+}
+
+func matchBrace(pattern string, open int) (int, []int) {
+	depth := 0
+
+	var commas []int
+
+	for i := open; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return i, commas
+			}
+		case ',':
+			if depth == 1 {
+				commas = append(commas, i)
+			}
+		}
+	}
+
+	return -1, nil
+}
+
+type segKind uint8
+
+const (
+	segLiteral segKind = iota
+	segSuffix
+	segGlob
+	segAnyDepth
+)
+
+// segment is a compiled glob for a single path segment. Literal and
+// "*suffix" segments take fast paths; everything else runs a small NFA.
+type segment struct {
+	source  string
+	kind    segKind
+	literal string
+	prog    []inst
+	start   int
+}
+
+func compileSegment(src string) (*segment, error) {
+	if src == "**" {
+		return &segment{source: src, kind: segAnyDepth}, nil
+	}
+
+	nodes, err := parseGlob(src)
+	if err != nil {
+		return nil, err
+	}
+
+	seg := &segment{source: src, kind: segGlob}
+
+	switch {
+	case allLiteral(nodes):
+		seg.kind = segLiteral
+		seg.literal = joinLiterals(nodes)
+	case nodes[0].op == nodeStar && allLiteral(nodes[1:]):
+		seg.kind = segSuffix
+		seg.literal = joinLiterals(nodes[1:])
+	default:
+		seg.prog = []inst{{op: opMatch}}
+		seg.start = seg.emitSeq(nodes, 0)
+	}
+
+	return seg, nil
+}
+
+// matchName reports whether a single path segment matches.
+func (s *segment) matchName(name string) bool {
+	switch s.kind {
+	case segLiteral:
+		return name == s.literal
+	case segSuffix:
+		return strings.HasSuffix(name, s.literal)
+	case segAnyDepth:
+		return true
+	default:
+		return s.run(name)
+	}
+}
+
+type nodeOp uint8
+
+const (
+	nodeLiteral nodeOp = iota
+	nodeAny
+	nodeStar
+	nodeClass
+	nodeGroup
+)
+
+// node is one element of a parsed segment glob.
+type node struct {
+	op    nodeOp
+	lit   byte
+	class *charClass
+	kind  byte // extglob operator: '@', '?', '*' or '+'
+	alts  [][]node
+}
+
+type charClass struct {
+	negate bool
+	ranges []runeRange
+}
+
+type runeRange struct {
+	lo, hi rune
+}
+
+func (c *charClass) matches(r rune) bool {
+	for _, rr := range c.ranges {
+		if rr.lo <= r && r <= rr.hi {
+			return !c.negate
+		}
+	}
+
+	return c.negate
+}
+
+// parseGlob parses a segment into nodes. Supported syntax: * ? [abc] [a-z]
+// [!abc] [^abc] \x escapes and the extglob groups @(a|b) ?(a|b) *(a|b) +(a|b).
+func parseGlob(src string) ([]node, error) {
+	var nodes []node
+
+	for i := 0; i < len(src); {
+		c := src[i]
+
+		if strings.IndexByte("@?*+", c) >= 0 && i+1 < len(src) && src[i+1] == '(' {
+			closeIdx, bars := matchParen(src, i+1)
+			if closeIdx >= 0 {
+				group := node{op: nodeGroup, kind: c}
+				start := i + 2
+
+				for _, end := range append(bars, closeIdx) {
+					alt, err := parseGlob(src[start:end])
+					if err != nil {
+						return nil, err
+					}
+
+					group.alts = append(group.alts, alt)
+					start = end + 1
+				}
+
+				nodes = append(nodes, group)
+				i = closeIdx + 1
+
+				continue
+			}
+		}
+
+		switch c {
+		case '*':
+			nodes = append(nodes, node{op: nodeStar})
+			i++
+		case '?':
+			nodes = append(nodes, node{op: nodeAny})
+			i++
+		case '[':
+			class, next, err := parseClass(src, i+1)
+			if err != nil {
+				return nil, err
+			}
+
+			nodes = append(nodes, node{op: nodeClass, class: class})
+			i = next
+		case '\\':
+			if i+1 >= len(src) {
+				return nil, errBadPattern
+			}
+
+			nodes = append(nodes, node{op: nodeLiteral, lit: src[i+1]})
+			i += 2
+		default:
+			nodes = append(nodes, node{op: nodeLiteral, lit: c})
+			i++
+		}
+	}
+
+	return nodes, nil
+}
+
+// matchParen finds the ")" closing the "(" at open and the top-level "|"
+// separators inside it. It returns -1 if the group is unterminated.
+func matchParen(src string, open int) (int, []int) {
+	depth := 0
+	inClass := false
+
+	var bars []int
+
+	for i := open; i < len(src); i++ {
+		switch c := src[i]; {
+		case c == '\\':
+			i++
+		case inClass:
+			if c == ']' {
+				inClass = false
+			}
+		case c == '[':
+			inClass = true
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+			if depth == 0 {
+				return i, bars
+			}
+		case c == '|' && depth == 1:
+			bars = append(bars, i)
+		}
+	}
+
+	return -1, nil
+}
+
+// parseClass parses a bracket expression starting after "[", following the
+// same rules as filepath.Match plus "!" as an alternative negation marker.
+func parseClass(src string, i int) (*charClass, int, error) {
+	class := &charClass{}
+
+	if i < len(src) && (src[i] == '^' || src[i] == '!') {
+		class.negate = true
+		i++
+	}
+
+	for {
+		if i < len(src) && src[i] == ']' && len(class.ranges) > 0 {
+			return class, i + 1, nil
+		}
+
+		lo, next, err := classRune(src, i)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		hi := lo
+		i = next
+
+		if i < len(src) && src[i] == '-' {
+			hi, i, err = classRune(src, i+1)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+
+		class.ranges = append(class.ranges, runeRange{lo: lo, hi: hi})
+	}
+}
+
+func classRune(src string, i int) (rune, int, error) {
+	if i >= len(src) || src[i] == '-' || src[i] == ']' {
+		return 0, 0, errBadPattern
+	}
+
+	if src[i] == '\\' {
+		i++
+		if i >= len(src) {
+			return 0, 0, errBadPattern
+		}
+	}
+
+	r, n := utf8.DecodeRuneInString(src[i:])
+	if r == utf8.RuneError && n == 1 {
+		return 0, 0, errBadPattern
+	}
+
+	if i+n >= len(src) {
+		return 0, 0, errBadPattern
+	}
+
+	return r, i + n, nil
+}
+
+func allLiteral(nodes []node) bool {
+	for _, n := range nodes {
+		if n.op != nodeLiteral {
+			return false
+		}
+	}
+
+	return true
+}
+
+func joinLiterals(nodes []node) string {
+	buf := make([]byte, len(nodes))
+	for i, n := range nodes {
+		buf[i] = n.lit
+	}
+
+	return string(buf)
+}
+
+type instOp uint8
+
+const (
+	opMatch instOp = iota
+	opByte
+	opAnyByte
+	opRune
+	opClass
+	opSplit
+)
+
+// inst is one NFA instruction. x is the next instruction; opSplit also tries y.
+type inst struct {
+	op    instOp
+	b     byte
+	class *charClass
+	x, y  int
+}
+
+// emitSeq compiles nodes so that a successful match continues at next,
+// returning the index of the first instruction.
+func (s *segment) emitSeq(nodes []node, next int) int {
+	for i := len(nodes) - 1; i >= 0; i-- {
+		next = s.emitNode(nodes[i], next)
+	}
+
+	return next
+}
+
+func (s *segment) emitNode(n node, next int) int {
+	switch n.op {
+	case nodeLiteral:
+		return s.emit(inst{op: opByte, b: n.lit, x: next})
+	case nodeAny:
+		return s.emit(inst{op: opRune, x: next})
+	case nodeClass:
+		return s.emit(inst{op: opClass, class: n.class, x: next})
+	case nodeStar:
+		// Like filepath.Match, * may end at any byte offset.
+		loop := s.emit(inst{op: opSplit, y: next})
+		s.prog[loop].x = s.emit(inst{op: opAnyByte, x: loop})
+
+		return loop
+	default:
+		return s.emitGroup(n, next)
+	}
+}
+
+func (s *segment) emitGroup(n node, next int) int {
+	switch n.kind {
+	case '?':
+		return s.emit(inst{op: opSplit, x: s.emitAlts(n.alts, next), y: next})
+	case '*':
+		loop := s.emit(inst{op: opSplit, y: next})
+		s.prog[loop].x = s.emitAlts(n.alts, loop)
+
+		return loop
+	case '+':
+		loop := s.emit(inst{op: opSplit, y: next})
+		start := s.emitAlts(n.alts, loop)
+		s.prog[loop].x = start
+
+		return start
+	default:
+		return s.emitAlts(n.alts, next)
+	}
+}
+
+func (s *segment) emitAlts(alts [][]node, next int) int {
+	start := s.emitSeq(alts[len(alts)-1], next)
+
+	for i := len(alts) - 2; i >= 0; i-- {
+		start = s.emit(inst{op: opSplit, x: s.emitSeq(alts[i], next), y: start})
+	}
+
+	return start
+}
+
+func (s *segment) emit(in inst) int {
+	s.prog = append(s.prog, in)
+
+	return len(s.prog) - 1
+}
+
+// run executes the NFA with a memoized depth-first search, which is linear
+// in len(prog)*len(name) regardless of how many stars the pattern has.
+func (s *segment) run(name string) bool {
+	width := len(name) + 1
+	visited := make([]bool, len(s.prog)*width)
+
+	var walk func(pc, pos int) bool
+
+	walk = func(pc, pos int) bool {
+		key := pc*width + pos
+		if visited[key] {
+			return false
+		}
+
+		visited[key] = true
+		in := s.prog[pc]
+
+		switch in.op {
+		case opMatch:
+			return pos == len(name)
+		case opByte:
+			return pos < len(name) && name[pos] == in.b && walk(in.x, pos+1)
+		case opAnyByte:
+			return pos < len(name) && walk(in.x, pos+1)
+		case opRune, opClass:
+			if pos >= len(name) {
+				return false
+			}
+
+			r, n := utf8.DecodeRuneInString(name[pos:])
+			if in.op == opClass && !in.class.matches(r) {
+				return false
+			}
+
+			return walk(in.x, pos+n)
+		default:
+			return walk(in.x, pos) || walk(in.y, pos)
+		}
+	}
+
+	return walk(s.start, 0)
+}
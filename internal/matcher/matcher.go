@@ -3,41 +3,39 @@ package matcher
 
 import (
 	"path/filepath"
+	"regexp"
 	"strings"
 )
 
+// RegexPrefix marks a pattern as a regular expression matched against the
+// slash-separated path instead of a glob, e.g. "re:^migrations/\d{4}_.*\.sql$".
+const RegexPrefix = "re:"
+
+// regexCache holds compiled regex patterns keyed by expression.
+var regexCache cache[*regexp.Regexp]
+
 // Matcher evaluates file paths against include and ignore glob patterns.
 type Matcher struct {
-	includes []string
-	ignores  []string
+	includes *Set
+	ignores  *Set
 }
 
 // New creates a Matcher with the given include and ignore patterns.
 func New(includes, ignores []string) *Matcher {
 	return &Matcher{
-		includes: includes,
-		ignores:  ignores,
+		includes: NewSet([][]string{includes}),
+		ignores:  NewSet([][]string{ignores}),
 	}
 }
 
 // Match returns true if the path matches any include pattern
 // and does not match any ignore pattern.
 func (m *Matcher) Match(path string) bool {
-	path = filepath.ToSlash(path)
-
-	for _, ign := range m.ignores {
-		if matchGlob(ign, path) {
-			return false
-		}
-	}
-
-	for _, inc := range m.includes {
-		if matchGlob(inc, path) {
-			return true
-		}
+	if m.ignores.MatchAny(path) {
+		return false
 	}
 
-	return false
+	return m.includes.MatchAny(path)
 }
 
 // MatchPattern checks a single pattern against a path.
@@ -45,39 +43,56 @@ func MatchPattern(pattern, path string) bool {
 	return matchGlob(pattern, filepath.ToSlash(path))
 }
 
-// matchGlob matches a glob pattern supporting *, **, and ? wildcards.
-// ** matches zero or more directory segments.
-func matchGlob(pattern, path string) bool {
-	pattern = filepath.ToSlash(pattern)
-	path = filepath.ToSlash(path)
-
-	// Handle ignore patterns that are bare names (e.g., ".git", "node_modules")
-	if !strings.Contains(pattern, "/") && !strings.Contains(pattern, "*") && !strings.Contains(pattern, "?") {
-		for seg := range strings.SplitSeq(path, "/") {
-			if seg == pattern {
-				return true
-			}
-		}
+// ValidatePattern reports an error if pattern cannot be compiled.
+func ValidatePattern(pattern string) error {
+	if expr, ok := strings.CutPrefix(pattern, RegexPrefix); ok {
+		_, err := compileRegex(expr)
 
-		return false
+		return err
 	}
 
-	return globMatch(pattern, path)
+	_, err := compilePattern(pattern)
+
+	return err
 }
 
-func globMatch(pattern, name string) bool {
-	// Split both pattern and path into segments
-	patParts := strings.Split(pattern, "/")
-	nameParts := strings.Split(name, "/")
+// IsNegated reports whether pattern is an exclusion entry ("!pattern").
+func IsNegated(pattern string) bool {
+	return strings.HasPrefix(pattern, NegatePrefix)
+}
+
+// matchGlob matches a glob pattern supporting *, **, ?, character classes,
+// braces, escapes and extglob groups. ** matches zero or more directory
+// segments. Patterns with RegexPrefix are matched as regular expressions,
+// and a negated pattern matches every path the rest of the pattern does not.
+// Pattern and path are both NFC-normalized first.
+func matchGlob(pattern, path string) bool {
+	path = normalize(filepath.ToSlash(path))
 
-	return matchSegments(patParts, nameParts)
+	if expr, ok := strings.CutPrefix(pattern, RegexPrefix); ok {
+		re, err := compileRegex(expr)
+		if err != nil {
+			return false
+		}
+
+		return re.MatchString(path)
+	}
+
+	cp, err := compilePattern(pattern)
+	if err != nil {
+		return false
+	}
+
+	return cp.match(path) != cp.negate
 }
 
-func matchSegments(patParts, nameParts []string) bool {
+// matchSegments is the reference matcher for a compiled glob: it backtracks
+// over ** and is used by MatchPattern; Set compiles the same segments into a trie.
+func matchSegments(patParts []*segment, nameParts []string) bool {
 	pi, ni := 0, 0
 
 	for pi < len(patParts) && ni < len(nameParts) {
-		if patParts[pi] == "**" {
+		if patParts[pi].kind == segAnyDepth {
 			for skip := ni; skip <= len(nameParts); skip++ {
 				if matchSegments(patParts[pi+1:], nameParts[skip:]) {
 					return true
@@ -87,7 +102,7 @@ func matchSegments(patParts, nameParts []string) bool {
 			return false
 		}
 
-		if !matchSegment(patParts[pi], nameParts[ni]) {
+		if !patParts[pi].matchName(nameParts[ni]) {
 			return false
 		}
 
@@ -96,35 +111,24 @@ func matchSegments(patParts, nameParts []string) bool {
 	}
 
 	// Consume trailing ** patterns
-	for pi < len(patParts) && patParts[pi] == "**" {
+	for pi < len(patParts) && patParts[pi].kind == segAnyDepth {
 		pi++
 	}
 
 	return pi == len(patParts) && ni == len(nameParts)
 }
 
-func matchSegment(pattern, name string) bool {
-	// Handle brace expansion: {a,b}
-	if idx := strings.Index(pattern, "{"); idx >= 0 {
-		end := strings.Index(pattern[idx:], "}")
-		if end >= 0 {
-			prefix := pattern[:idx]
-
-			suffix := pattern[idx+end+1:]
-			for alt := range strings.SplitSeq(pattern[idx+1:idx+end], ",") {
-				if matchSegment(prefix+alt+suffix, name) {
-					return true
-				}
-			}
-
-			return false
-		}
+func compileRegex(expr string) (*regexp.Regexp, error) {
+	if re, ok := regexCache.load(expr); ok {
+		return re, nil
 	}
 
-	matched, err := filepath.Match(pattern, name)
+	re, err := regexp.Compile(normalize(expr))
 	if err != nil {
-		return false
+		return nil, err
 	}
 
-	return matched
+	regexCache.store(expr, re)
+
+	return re, nil
 }
@@ -1,6 +1,9 @@
 package matcher
 
 import (
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 )
 
@@ -49,6 +52,63 @@ func TestMatchPattern(t *testing.T) {
 		// Wildcard ignore patterns
 		{"wildcard ignore", "*.tmp", "cache.tmp", true},
 		{"doublestar ignore", "**/*.swp", "src/deep/file.swp", true},
+
+		// Brace expansion across segments and nesting
+		{"brace across segments src", "{src,test}/**/*.go", "src/a/b.go", true},
+		{"brace across segments test", "{src,test}/**/*.go", "test/b.go", true},
+		{"brace across segments no match", "{src,test}/**/*.go", "lib/b.go", false},
+		{"brace with slash", "{cmd/*,internal/**}/*.go", "internal/a/b/c.go", true},
+		{"nested braces", "*.{go,{ts,tsx}}", "app.tsx", true},
+		{"multiple braces", "{a,b}/{c,d}.txt", "b/c.txt", true},
+		{"multiple braces no match", "{a,b}/{c,d}.txt", "b/e.txt", false},
+		{"bare names in braces", "{.git,node_modules}", "x/node_modules/y.js", true},
+		{"escaped brace", `\{a,b\}.txt`, "{a,b}.txt", true},
+		{"escaped brace literal only", `\{a,b\}.txt`, "a.txt", false},
+
+		// Character classes
+		{"class", "[ab]*.txt", "alpha.txt", true},
+		{"class no match", "[ab]*.txt", "gamma.txt", false},
+		{"class range", "v[0-9].go", "v7.go", true},
+		{"class bang negation", "[!abc]*.go", "main.go", true},
+		{"class bang negation no match", "[!abc]*.go", "app.go", false},
+		{"class caret negation", "[^abc]*.go", "app.go", false},
+
+		// Escapes
+		{"escaped star", `\*.go`, "*.go", true},
+		{"escaped star literal only", `\*.go`, "main.go", false},
+		{"escaped question", `file\?.txt`, "file?.txt", true},
+
+		// Extglob
+		{"extglob at", "*.@(js|ts)", "app.ts", true},
+		{"extglob at no match", "*.@(js|ts)", "app.go", false},
+		{"extglob optional", "main?(_test).go", "main_test.go", true},
+		{"extglob optional absent", "main?(_test).go", "main.go", true},
+		{"extglob plus", "+(ab).txt", "ababab.txt", true},
+		{"extglob plus needs one", "+(ab).txt", ".txt", false},
+		{"extglob star", "x*(ab).txt", "x.txt", true},
+		{"extglob nested glob", "@(*.go|[a-c]*.md)", "b.md", true},
+
+		// Case-insensitive flag
+		{"ignore case", "(?i)*.png", "Logo.PNG", true},
+		{"ignore case dirs", "(?i)assets/**/*.png", "ASSETS/img/a.Png", true},
+		{"case sensitive by default", "*.png", "Logo.PNG", false},
+		{"ignore case negated", "!(?i)*.bak", "OLD.BAK", false},
+		{"ignore case bare name", "(?i)node_modules", "web/Node_Modules/x.js", true},
+
+		// Unicode normalization (NFD path vs NFC pattern and vice versa)
+		{"nfc pattern nfd path", "caf\u00e9/*.txt", "cafe\u0301/menu.txt", true},
+		{"nfd pattern nfc path", "cafe\u0301.md", "caf\u00e9.md", true},
+		{"nfc class nfd path", "[\u00e9]*.txt", "e\u0301t\u00e9.txt", true},
+
+		// Negation inverts a single pattern
+		{"negated", "!*.go", "main.rs", true},
+		{"negated no match", "!*.go", "main.go", false},
+
+		// Regex patterns
+		{"regex match", `re:^migrations/\d{4}_.*\.sql$`, "migrations/0001_init.sql", true},
+		{"regex no match", `re:^migrations/\d{4}_.*\.sql$`, "migrations/init.sql", false},
+		{"regex unanchored", `re:_test\.go$`, "pkg/a/b_test.go", true},
+		{"regex invalid", "re:([", "anything", false},
 	}
 
 	for _, tt := range tests {
@@ -102,3 +162,115 @@ func TestDoubleStarMatchesZeroSegments(t *testing.T) {
 		t.Error("** should match zero directory segments")
 	}
 }
+
+func TestValidatePattern(t *testing.T) {
+	err := ValidatePattern("**/*.go")
+	if err != nil {
+		t.Errorf("unexpected error for glob: %v", err)
+	}
+
+	err = ValidatePattern(`re:^\w+\.go$`)
+	if err != nil {
+		t.Errorf("unexpected error for regex: %v", err)
+	}
+
+	err = ValidatePattern("re:([")
+	if err == nil {
+		t.Error("expected error for invalid regex")
+	}
+
+	for _, bad := range []string{"[", "[]", "a\\", "!", strings.Repeat("{a,b}", 11)} {
+		err = ValidatePattern(bad)
+		if err == nil {
+			t.Errorf("expected error for invalid glob %q", bad)
+		}
+	}
+}
+
+func TestExpandBraces(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    []string
+	}{
+		{"a", []string{"a"}},
+		{"{a,b}", []string{"a", "b"}},
+		{"x{a,b}y{1,2}", []string{"xay1", "xay2", "xby1", "xby2"}},
+		{"{a,{b,c}d}", []string{"a", "bd", "cd"}},
+		{"{src,test}/**", []string{"src/**", "test/**"}},
+		{"{a,b", []string{"{a,b"}},
+		{`\{a,b}`, []string{`\{a,b}`}},
+		{`{a\,b,c}`, []string{`a\,b`, "c"}},
+	}
+
+	for _, tt := range tests {
+		got, err := expandBraces(tt.pattern)
+		if err != nil {
+			t.Fatalf("expandBraces(%q): %v", tt.pattern, err)
+		}
+
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("expandBraces(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+// FuzzSegmentMatchesFilepathMatch checks the segment compiler against
+// filepath.Match for the syntax both support.
+func FuzzSegmentMatchesFilepathMatch(f *testing.F) {
+	for _, seed := range [][2]string{
+		{"*.go", "main.go"}, {"?.go", "a.go"}, {"[a-c]*", "beta"}, {"[^a]?", "bc"},
+		{`\*x`, "*x"}, {"*a*b*c", "xaybzc"}, {"[\\]]", "]"}, {"*", ""}, {"a*é", "aé"},
+	} {
+		f.Add(seed[0], seed[1])
+	}
+
+	f.Fuzz(func(t *testing.T, pattern, name string) {
+		if strings.ContainsAny(pattern, "{}()!/") || strings.Contains(name, "/") {
+			t.Skip()
+		}
+
+		want, refErr := filepath.Match(pattern, name)
+		if refErr != nil {
+			t.Skip()
+		}
+
+		// filepath.Match validates lazily and may accept malformed
+		// patterns when it fails early; compileSegment always rejects them.
+		seg, err := compileSegment(pattern)
+		if err != nil {
+			t.Skip()
+		}
+
+		if seg.kind == segAnyDepth {
+			t.Skip()
+		}
+
+		got := seg.matchName(name)
+		if got != want {
+			t.Errorf("segment %q on %q = %v, filepath.Match = %v", pattern, name, got, want)
+		}
+	})
+}
+
+// FuzzSetMatchesMatchPattern checks the trie-based Set against the
+// backtracking reference matcher used by MatchPattern.
+func FuzzSetMatchesMatchPattern(f *testing.F) {
+	for _, pattern := range setTestPatterns {
+		for _, path := range setTestPaths[:4] {
+			f.Add(pattern, path)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, pattern, path string) {
+		if IsNegated(pattern) || ValidatePattern(pattern) != nil {
+			t.Skip()
+		}
+
+		want := MatchPattern(pattern, path)
+
+		got := NewSet([][]string{{pattern}}).MatchAny(path)
+		if got != want {
+			t.Errorf("Set(%q).MatchAny(%q) = %v, MatchPattern = %v", pattern, path, got, want)
+		}
+	})
+}
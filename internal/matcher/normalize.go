@@ -0,0 +1,46 @@
+package matcher
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// IgnoreCasePrefix marks a glob pattern as case-insensitive, e.g. "(?i)*.png".
+// In a negated entry it follows the "!": "!(?i)*.bak".
+const IgnoreCasePrefix = "(?i)"
+
+// Option configures a Set.
+type Option func(*options)
+
+type options struct {
+	ignoreCase bool
+}
+
+// IgnoreCase makes every pattern in the Set case-insensitive, as if each
+// carried the IgnoreCasePrefix flag.
+func IgnoreCase() Option {
+	return func(o *options) {
+		o.ignoreCase = true
+	}
+}
+
+// normalize converts s to Unicode NFC so that decomposed names (as written
+// by macOS archives) match composed patterns and vice versa.
+func normalize(s string) string {
+	if norm.NFC.IsNormalString(s) {
+		return s
+	}
+
+	return norm.NFC.String(s)
+}
+
+// fold returns the case-folded form of an NFC-normalized string.
+func fold(s string) string {
+	return strings.ToLower(s)
+}
+
+// cutIgnoreCase strips a leading IgnoreCasePrefix from a glob pattern.
+func cutIgnoreCase(pattern string) (string, bool) {
+	return strings.CutPrefix(pattern, IgnoreCasePrefix)
+}
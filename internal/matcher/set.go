@@ -0,0 +1,376 @@
+package matcher
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Set is a precompiled collection of pattern groups. Glob patterns are
+// compiled into a trie keyed by path segment, so patterns that share a
+// literal prefix (e.g. "src/**") or extension (e.g. "*.go") are evaluated
+// once per event rather than once per pattern.
+//
+// A group matches a path when any of its positive patterns matches and none
+// of its negated ("!pattern") entries do. Case-insensitive patterns live in
+// separate tries matched against the case-folded path.
+//
+// A Set is immutable after construction and safe for concurrent use.
+type Set struct {
+	groups  int
+	opts    options
+	include trieSet
+	exclude trieSet
+}
+
+// trieSet holds the case-sensitive and case-folded tries of one polarity.
+type trieSet struct {
+	exact  *trie
+	folded *trie
+}
+
+// setPath is a path prepared for evaluation; the folded form is computed
+// only if a case-insensitive trie needs it.
+type setPath struct {
+	path     string
+	segments []string
+	folded   string
+	foldSegs []string
+}
+
+type trie struct {
+	root   *setNode
+	bare   map[string][]int
+	regexs []setRegex
+	empty  bool
+}
+
+type setNode struct {
+	literal    map[string]*setNode
+	suffix     map[string]*setNode
+	wildcards  []setEdge
+	doublestar *setNode
+	terminal   []int
+	anyDepth   bool
+}
+
+// setEdge is a transition on a segment that needs the full glob matcher.
+type setEdge struct {
+	seg  *segment
+	next *setNode
+}
+
+type setRegex struct {
+	re    *regexp.Regexp
+	group int
+}
+
+// NewSet compiles groups of patterns into a Set. Each pattern follows the
+// same rules as MatchPattern. Patterns that fail to compile never match.
+func NewSet(groups [][]string, opts ...Option) *Set {
+	s := &Set{
+		groups:  len(groups),
+		include: trieSet{exact: newTrie(), folded: newTrie()},
+		exclude: trieSet{exact: newTrie(), folded: newTrie()},
+	}
+
+	for _, opt := range opts {
+		opt(&s.opts)
+	}
+
+	for i, patterns := range groups {
+		for _, pattern := range patterns {
+			s.add(pattern, i)
+		}
+	}
+
+	return s
+}
+
+// Len returns the number of groups in the set.
+func (s *Set) Len() int {
+	return s.groups
+}
+
+// Match returns the indices of all groups with a pattern matching path,
+// in ascending order.
+func (s *Set) Match(path string) []int {
+	hits := s.eval(filepath.ToSlash(path), false)
+
+	var out []int
+
+	for i, hit := range hits {
+		if hit {
+			out = append(out, i)
+		}
+	}
+
+	return out
+}
+
+// MatchAny reports whether any group in the set matches path.
+func (s *Set) MatchAny(path string) bool {
+	for _, hit := range s.eval(filepath.ToSlash(path), true) {
+		if hit {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Set) eval(path string, first bool) []bool {
+	p := &setPath{path: normalize(path)}
+	p.segments = strings.Split(p.path, "/")
+
+	// Without exclusions the first positive hit settles MatchAny.
+	hits := s.include.eval(p, s.groups, first && s.exclude.empty())
+	if hits == nil || s.exclude.empty() {
+		return hits
+	}
+
+	for i, ex := range s.exclude.eval(p, s.groups, false) {
+		if ex {
+			hits[i] = false
+		}
+	}
+
+	return hits
+}
+
+func (s *Set) add(pattern string, group int) {
+	ts := s.include
+	if IsNegated(pattern) {
+		ts = s.exclude
+		pattern = strings.TrimPrefix(pattern, NegatePrefix)
+	}
+
+	if expr, ok := strings.CutPrefix(pattern, RegexPrefix); ok {
+		if s.opts.ignoreCase {
+			expr = "(?i)" + expr
+		}
+
+		re, err := compileRegex(expr)
+		if err == nil {
+			ts.exact.regexs = append(ts.exact.regexs, setRegex{re: re, group: group})
+			ts.exact.empty = false
+		}
+
+		return
+	}
+
+	if _, ok := cutIgnoreCase(pattern); !ok && s.opts.ignoreCase {
+		pattern = IgnoreCasePrefix + pattern
+	}
+
+	cp, err := compilePattern(pattern)
+	if err != nil {
+		return
+	}
+
+	t := ts.exact
+	if cp.fold {
+		t = ts.folded
+	}
+
+	t.add(cp, group)
+}
+
+func (ts trieSet) empty() bool {
+	return ts.exact.empty && ts.folded.empty
+}
+
+func (ts trieSet) eval(p *setPath, groups int, first bool) []bool {
+	hits := ts.exact.eval(p.path, p.segments, groups, first)
+	if ts.folded.empty || (first && hits != nil) {
+		return hits
+	}
+
+	if p.foldSegs == nil {
+		p.folded = fold(p.path)
+		p.foldSegs = strings.Split(p.folded, "/")
+	}
+
+	folded := ts.folded.eval(p.folded, p.foldSegs, groups, first)
+	if hits == nil {
+		return folded
+	}
+
+	for i, hit := range folded {
+		hits[i] = hits[i] || hit
+	}
+
+	return hits
+}
+
+func (t *trie) add(cp *compiledPattern, group int) {
+	t.empty = false
+
+	for _, name := range cp.bare {
+		t.bare[name] = append(t.bare[name], group)
+	}
+
+	for _, segs := range cp.globs {
+		n := t.root
+
+		for _, seg := range segs {
+			n = n.child(seg)
+		}
+
+		n.terminal = append(n.terminal, group)
+	}
+}
+
+func newTrie() *trie {
+	return &trie{
+		root:  &setNode{},
+		bare:  make(map[string][]int),
+		empty: true,
+	}
+}
+
+// eval walks the trie once over the path segments. When first is set it
+// returns as soon as any group matches.
+func (t *trie) eval(path string, segments []string, groups int, first bool) []bool {
+	if t.empty {
+		return nil
+	}
+
+	var hits []bool
+
+	hit := func(ids []int) bool {
+		if len(ids) == 0 {
+			return false
+		}
+
+		if hits == nil {
+			hits = make([]bool, groups)
+		}
+
+		for _, g := range ids {
+			hits[g] = true
+		}
+
+		return first
+	}
+
+	for _, seg := range segments {
+		if hit(t.bare[seg]) {
+			return hits
+		}
+	}
+
+	for _, r := range t.regexs {
+		if r.re.MatchString(path) && hit([]int{r.group}) {
+			return hits
+		}
+	}
+
+	active := closure(nil, t.root)
+
+	for _, seg := range segments {
+		if len(active) == 0 {
+			break
+		}
+
+		var next []*setNode
+
+		for _, n := range active {
+			next = n.step(next, seg)
+		}
+
+		active = next
+	}
+
+	for _, n := range active {
+		if hit(n.terminal) {
+			return hits
+		}
+	}
+
+	return hits
+}
+
+// child returns the node reached from n by the pattern segment seg,
+// creating it if needed.
+func (n *setNode) child(seg *segment) *setNode {
+	switch seg.kind {
+	case segAnyDepth:
+		if n.doublestar == nil {
+			n.doublestar = &setNode{anyDepth: true}
+		}
+
+		return n.doublestar
+	case segLiteral:
+		return lookup(&n.literal, seg.literal)
+	case segSuffix:
+		return lookup(&n.suffix, seg.literal)
+	default:
+		for _, e := range n.wildcards {
+			if e.seg.source == seg.source {
+				return e.next
+			}
+		}
+
+		next := &setNode{}
+		n.wildcards = append(n.wildcards, setEdge{seg: seg, next: next})
+
+		return next
+	}
+}
+
+// step appends to dst every node reachable from n by consuming seg.
+func (n *setNode) step(dst []*setNode, seg string) []*setNode {
+	if n.anyDepth {
+		dst = closure(dst, n)
+	}
+
+	if next, ok := n.literal[seg]; ok {
+		dst = closure(dst, next)
+	}
+
+	for suffix, next := range n.suffix {
+		if strings.HasSuffix(seg, suffix) {
+			dst = closure(dst, next)
+		}
+	}
+
+	for _, e := range n.wildcards {
+		if e.seg.matchName(seg) {
+			dst = closure(dst, e.next)
+		}
+	}
+
+	return dst
+}
+
+// closure appends n and every node reachable from it through a "**" that
+// matches zero segments. A "**" node stays active to consume further segments.
+func closure(dst []*setNode, n *setNode) []*setNode {
+	for n != nil {
+		for _, existing := range dst {
+			if existing == n {
+				return dst
+			}
+		}
+
+		dst = append(dst, n)
+		n = n.doublestar
+	}
+
+	return dst
+}
+
+func lookup(m *map[string]*setNode, key string) *setNode {
+	if *m == nil {
+		*m = make(map[string]*setNode)
+	}
+
+	next, ok := (*m)[key]
+	if !ok {
+		next = &setNode{}
+		(*m)[key] = next
+	}
+
+	return next
+}
@@ -0,0 +1,212 @@
+package matcher
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+var setTestPatterns = []string{
+	"main.go", "*.go", "?.go", "**/*.go", "src/**/*.ts", "*.{go,rs}", "cmd/**",
+	".git", "node_modules", "*.tmp", "**/*.swp", "**", "**/**/*.md", "a/**/b/*.c",
+	"docs/*/index.{md,html}", "[ab]*.txt", `re:^migrations/\d+_.*\.sql$`, "assets/**/*.css",
+	"{src,test}/**/*.go", "*.@(js|ts)", "[!a]*.txt", `\*.go`, "{.git,node_modules}",
+}
+
+var setTestPaths = []string{
+	"main.go", "other.go", "a.go", "ab.go", "cmd/main.go", "cmd/server/main.go", "cmd",
+	"src/app/index.ts", "src/index.ts", "lib/index.ts", "lib.rs", ".git/config",
+	"some/node_modules/pkg.js", "cache.tmp", "src/deep/file.swp", "README.md",
+	"x/y/z/notes.md", "a/b/x.c", "a/x/y/b/z.c", "a/b/c", "docs/guide/index.md",
+	"docs/guide/index.html", "docs/index.md", "alpha.txt", "gamma.txt",
+	"migrations/0001_init.sql", "assets/css/site.css", "", "test/x/y.go", "app.ts", "*.go",
+}
+
+func TestSetMatchesMatchPattern(t *testing.T) {
+	for _, pattern := range setTestPatterns {
+		s := NewSet([][]string{{pattern}})
+
+		for _, path := range setTestPaths {
+			want := MatchPattern(pattern, path)
+
+			got := s.MatchAny(path)
+			if got != want {
+				t.Errorf("Set(%q).MatchAny(%q) = %v, MatchPattern = %v", pattern, path, got, want)
+			}
+		}
+	}
+}
+
+func TestSetMatchReturnsGroupIndices(t *testing.T) {
+	s := NewSet([][]string{
+		{"**/*.go"},
+		{"assets/**/*.css"},
+		{"**/*"},
+		{"*.md", "docs/**"},
+	})
+
+	tests := []struct {
+		path string
+		want []int
+	}{
+		{"cmd/main.go", []int{0, 2}},
+		{"assets/style/main.css", []int{1, 2}},
+		{"docs/guide.txt", []int{2, 3}},
+		{"README.md", []int{2, 3}},
+	}
+
+	for _, tt := range tests {
+		got := s.Match(tt.path)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Match(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSetNegation(t *testing.T) {
+	s := NewSet([][]string{
+		{"**/*.go", "!**/*_test.go", "!vendor/**"},
+		{"**/*_test.go"},
+	})
+
+	tests := []struct {
+		path string
+		want []int
+	}{
+		{"cmd/main.go", []int{0}},
+		{"cmd/main_test.go", []int{1}},
+		{"vendor/pkg/a.go", nil},
+	}
+
+	for _, tt := range tests {
+		got := s.Match(tt.path)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Match(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	if s.MatchAny("vendor/pkg/a.go") {
+		t.Error("excluded path should not match any group")
+	}
+}
+
+func TestSetIgnoreCase(t *testing.T) {
+	s := NewSet([][]string{
+		{"*.png", "re:^docs/"},
+		{"README.md"},
+		{"(?i)*.jpg", "!(?i)thumb_*"},
+	}, IgnoreCase())
+
+	tests := []struct {
+		path string
+		want []int
+	}{
+		{"Logo.PNG", []int{0}},
+		{"DOCS/index.md", []int{0}},
+		{"sub/readme.MD", []int{1}},
+		{"Photo.JPG", []int{2}},
+		{"THUMB_photo.jpg", nil},
+	}
+
+	for _, tt := range tests {
+		got := s.Match(tt.path)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("Match(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSetMixedCaseFlags(t *testing.T) {
+	s := NewSet([][]string{{"*.png"}, {"(?i)*.png"}})
+
+	got := s.Match("Logo.PNG")
+	if !slices.Equal(got, []int{1}) {
+		t.Errorf("Match(Logo.PNG) = %v, want [1]", got)
+	}
+
+	got = s.Match("logo.png")
+	if !slices.Equal(got, []int{0, 1}) {
+		t.Errorf("Match(logo.png) = %v, want [0 1]", got)
+	}
+}
+
+func TestSetNormalizesUnicode(t *testing.T) {
+	s := NewSet([][]string{{"caf\u00e9/**"}})
+
+	if !s.MatchAny("cafe\u0301/menu.txt") {
+		t.Error("expected NFD path to match NFC pattern")
+	}
+}
+
+func TestSetEmpty(t *testing.T) {
+	s := NewSet(nil)
+
+	if s.MatchAny("main.go") {
+		t.Error("empty set should not match")
+	}
+
+	if s.Match("main.go") != nil {
+		t.Error("empty set should return no groups")
+	}
+}
+
+func benchmarkPatterns() [][]string {
+	exts := []string{"go", "ts", "tsx", "js", "css", "scss", "md", "yaml", "json", "sql"}
+	dirs := []string{"src", "lib", "cmd", "internal", "pkg", "web", "assets", "docs", "test", "scripts"}
+
+	groups := make([][]string, 0, 300)
+
+	for _, d := range dirs {
+		for _, e := range exts {
+			groups = append(groups,
+				[]string{d + "/**/*." + e},
+				[]string{d + "/*/*_test." + e},
+				[]string{fmt.Sprintf("%s/gen/**/*.%s", d, e)},
+			)
+		}
+	}
+
+	return groups
+}
+
+var benchmarkPaths = []string{
+	"node_modules/.pnpm/react@18.2.0/node_modules/react/index.js",
+	"src/components/button/Button.tsx",
+	"internal/rule/engine.go",
+	"web/static/css/site.css",
+	"README.md",
+}
+
+func BenchmarkSetMatch(b *testing.B) {
+	s := NewSet(benchmarkPatterns())
+
+	b.ResetTimer()
+
+	for i := range b.N {
+		_ = s.Match(benchmarkPaths[i%len(benchmarkPaths)])
+	}
+}
+
+func BenchmarkMatchPatternLoop(b *testing.B) {
+	groups := benchmarkPatterns()
+
+	b.ResetTimer()
+
+	for i := range b.N {
+		path := benchmarkPaths[i%len(benchmarkPaths)]
+
+		var out []int
+
+		for g, patterns := range groups {
+			for _, p := range patterns {
+				if MatchPattern(p, path) {
+					out = append(out, g)
+
+					break
+				}
+			}
+		}
+
+		_ = out
+	}
+}
@@ -0,0 +1,286 @@
+package metrics
+
+import (
+	"bufio"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// family is a metric family that can write itself in the Prometheus text
+// exposition format.
+type family interface {
+	write(w *bufio.Writer)
+}
+
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (d *desc) header(w *bufio.Writer) {
+	_, _ = w.WriteString("# HELP " + d.name + " " + d.help + "\n")
+	_, _ = w.WriteString("# TYPE " + d.name + " " + d.typ + "\n")
+}
+
+// labelKey joins label values into a map key. The separator cannot occur
+// in valid UTF-8 text.
+func labelKey(values []string) string {
+	return strings.Join(values, "\xff")
+}
+
+// formatLabels renders label pairs as {a="x",b="y"}, with extra appended
+// verbatim (used for a histogram's le label).
+func formatLabels(names, values []string, extra string) string {
+	if len(names) == 0 && extra == "" {
+		return ""
+	}
+
+	var b strings.Builder
+
+	b.WriteByte('{')
+
+	for i, name := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		b.WriteString(name + `="` + escapeLabel(values[i]) + `"`)
+	}
+
+	if extra != "" {
+		if len(names) > 0 {
+			b.WriteByte(',')
+		}
+
+		b.WriteString(extra)
+	}
+
+	b.WriteByte('}')
+
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+// CounterVec is a set of monotonically increasing counters partitioned by
+// label values.
+type CounterVec struct {
+	desc
+
+	mu     sync.Mutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	values []string
+	count  uint64
+}
+
+func newCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{
+		desc:   desc{name: name, help: help, typ: "counter", labels: labels},
+		series: make(map[string]*counterSeries),
+	}
+}
+
+// Inc adds one to the counter with the given label values, which must
+// match the vector's label names in number and order.
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds n to the counter with the given label values.
+func (c *CounterVec) Add(n uint64, values ...string) {
+	key := labelKey(values)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.series[key]
+	if !ok {
+		s = &counterSeries{values: slices.Clone(values)}
+		c.series[key] = s
+	}
+
+	s.count += n
+}
+
+// Value returns the current count for the given label values.
+func (c *CounterVec) Value(values ...string) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s, ok := c.series[labelKey(values)]
+	if !ok {
+		return 0
+	}
+
+	return s.count
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.header(w)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// An unlabeled counter is always exported, starting at zero.
+	if len(c.labels) == 0 && len(c.series) == 0 {
+		_, _ = w.WriteString(c.name + " 0\n")
+
+		return
+	}
+
+	for _, key := range sortedKeys(c.series) {
+		s := c.series[key]
+		_, _ = w.WriteString(c.name + formatLabels(c.labels, s.values, "") + " " + strconv.FormatUint(s.count, 10) + "\n")
+	}
+}
+
+// GaugeFunc is a gauge whose value is read when metrics are scraped.
+type GaugeFunc struct {
+	desc
+
+	mu sync.Mutex
+	fn func() float64
+}
+
+func newGaugeFunc(name, help string) *GaugeFunc {
+	return &GaugeFunc{desc: desc{name: name, help: help, typ: "gauge"}}
+}
+
+// Set replaces the function that reports the gauge's value.
+func (g *GaugeFunc) Set(fn func() float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.fn = fn
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	g.mu.Lock()
+	fn := g.fn
+	g.mu.Unlock()
+
+	value := 0.0
+	if fn != nil {
+		value = fn()
+	}
+
+	g.header(w)
+	_, _ = w.WriteString(g.name + " " + formatFloat(value) + "\n")
+}
+
+// HistogramVec is a set of histograms partitioned by label values.
+type HistogramVec struct {
+	desc
+
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+func newHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return &HistogramVec{
+		desc:    desc{name: name, help: help, typ: "histogram", labels: labels},
+		buckets: buckets,
+		series:  make(map[string]*histogramSeries),
+	}
+}
+
+// Observe records v in the histogram with the given label values.
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	key := labelKey(values)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[key]
+	if !ok {
+		s = &histogramSeries{values: slices.Clone(values), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = s
+	}
+
+	for i, upper := range h.buckets {
+		if v <= upper {
+			s.counts[i]++
+		}
+	}
+
+	s.sum += v
+	s.count++
+}
+
+// Count returns the number of observations for the given label values.
+func (h *HistogramVec) Count(values ...string) uint64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, ok := h.series[labelKey(values)]
+	if !ok {
+		return 0
+	}
+
+	return s.count
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.header(w)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, key := range sortedKeys(h.series) {
+		s := h.series[key]
+
+		for i, upper := range h.buckets {
+			le := `le="` + formatFloat(upper) + `"`
+			_, _ = w.WriteString(h.name + "_bucket" + formatLabels(h.labels, s.values, le) + " " + strconv.FormatUint(s.counts[i], 10) + "\n")
+		}
+
+		count := strconv.FormatUint(s.count, 10)
+		labels := formatLabels(h.labels, s.values, "")
+
+		_, _ = w.WriteString(h.name + "_bucket" + formatLabels(h.labels, s.values, `le="+Inf"`) + " " + count + "\n")
+		_, _ = w.WriteString(h.name + "_sum" + labels + " " + formatFloat(s.sum) + "\n")
+		_, _ = w.WriteString(h.name + "_count" + labels + " " + count + "\n")
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	slices.Sort(keys)
+
+	return keys
+}
@@ -0,0 +1,182 @@
+// Package metrics exposes watchdog's counters, gauges and histograms in the
+// Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/action"
+	"github.com/devaloi/watchdog/internal/watcher"
+	"github.com/fsnotify/fsnotify"
+)
+
+// Action results used as the result label of watchdog_actions_total.
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+const (
+	// ContentType is the Content-Type of the /metrics response.
+	ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+	shutdownTimeout   = 5 * time.Second
+	readHeaderTimeout = 5 * time.Second
+)
+
+// durationBuckets are histogram upper bounds in seconds, from 5ms for fast
+// webhooks to 5 minutes for long builds.
+var durationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 300}
+
+// Metrics holds every metric watchdog exports. It is safe for concurrent use.
+type Metrics struct {
+	Events          *CounterVec
+	Filtered        *CounterVec
+	Matches         *CounterVec
+	Fires           *CounterVec
+	Actions         *CounterVec
+	WebhookDuration *HistogramVec
+	CommandDuration *HistogramVec
+	WatchedDirs     *GaugeFunc
+	WatchErrors     *CounterVec
+	Overflows       *CounterVec
+
+	families []family
+}
+
+// New creates a Metrics with all counters at zero.
+func New() *Metrics {
+	m := &Metrics{
+		Events:          newCounterVec("watchdog_events_total", "Raw file system events received, by type.", "type"),
+		Filtered:        newCounterVec("watchdog_events_filtered_total", "Events dropped by ignore patterns or because no rule matched."),
+		Matches:         newCounterVec("watchdog_rule_matches_total", "Events matched by each rule, before debouncing.", "rule"),
+		Fires:           newCounterVec("watchdog_debounced_fires_total", "Debounced rule triggers that ran an action.", "rule"),
+		Actions:         newCounterVec("watchdog_actions_total", "Action executions by rule, action type and result.", "rule", "action", "result"),
+		WebhookDuration: newHistogramVec("watchdog_webhook_duration_seconds", "Webhook request latency.", durationBuckets, "rule"),
+		CommandDuration: newHistogramVec("watchdog_command_duration_seconds", "Command run time from start to exit.", durationBuckets, "rule"),
+		WatchedDirs:     newGaugeFunc("watchdog_watched_directories", "Directories currently watched."),
+		WatchErrors:     newCounterVec("watchdog_watch_errors_total", "Errors reported by the file system watcher."),
+		Overflows:       newCounterVec("watchdog_watch_overflows_total", "Kernel event queue overflows, each recovered by a resync scan."),
+	}
+
+	m.families = []family{
+		m.Events, m.Filtered, m.Matches, m.Fires, m.Actions,
+		m.WebhookDuration, m.CommandDuration, m.WatchedDirs, m.WatchErrors, m.Overflows,
+	}
+
+	return m
+}
+
+// ObserveEvent counts a raw event from the watcher.
+func (m *Metrics) ObserveEvent(ev watcher.Event) {
+	m.Events.Inc(string(ev.Type))
+}
+
+// ObserveFiltered counts an event that no rule acted on.
+func (m *Metrics) ObserveFiltered() {
+	m.Filtered.Inc()
+}
+
+// ObserveMatch counts an event matched by a rule.
+func (m *Metrics) ObserveMatch(ruleName string) {
+	m.Matches.Inc(ruleName)
+}
+
+// ObserveFire counts a debounced trigger of a rule.
+func (m *Metrics) ObserveFire(ruleName string) {
+	m.Fires.Inc(ruleName)
+}
+
+// ObserveAction records an action's result. Webhook latency is recorded
+// here; command run time is recorded by ObserveCommandExit, since a
+// command action returns once the process has started.
+func (m *Metrics) ObserveAction(ruleName, actionType string, err error, elapsed time.Duration) {
+	result := ResultSuccess
+	if err != nil {
+		result = ResultFailure
+	}
+
+	m.Actions.Inc(ruleName, actionType, result)
+
+	if actionType == "webhook" {
+		m.WebhookDuration.Observe(elapsed.Seconds(), ruleName)
+	}
+}
+
+// ObserveCommandExit records the run time of a finished command. Runs
+// canceled by a re-trigger are not recorded.
+func (m *Metrics) ObserveCommandExit(ruleName string, res action.Result) {
+	if res.Canceled {
+		return
+	}
+
+	m.CommandDuration.Observe(res.Duration.Seconds(), ruleName)
+}
+
+// ObserveWatchError counts an error from the watcher, separating queue
+// overflows from other errors.
+func (m *Metrics) ObserveWatchError(err error) {
+	if errors.Is(err, fsnotify.ErrEventOverflow) {
+		m.Overflows.Inc()
+
+		return
+	}
+
+	m.WatchErrors.Inc()
+}
+
+// TrackWatchedDirs reports the source's directory count at scrape time.
+func (m *Metrics) TrackWatchedDirs(w watcher.Source) {
+	m.WatchedDirs.Set(func() float64 {
+		return float64(len(w.WatchedDirs()))
+	})
+}
+
+// Handler returns an http.Handler serving all metrics.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Header().Set("Content-Type", ContentType)
+
+		bw := bufio.NewWriter(rw)
+
+		for _, f := range m.families {
+			f.write(bw)
+		}
+
+		_ = bw.Flush()
+	})
+}
+
+// Serve serves /metrics on addr until ctx is canceled.
+func (m *Metrics) Serve(ctx context.Context, addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", m.Handler())
+
+	ln, err := (&net.ListenConfig{}).Listen(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: readHeaderTimeout}
+
+	go func() {
+		<-ctx.Done()
+
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), shutdownTimeout)
+		defer cancel()
+
+		_ = srv.Shutdown(shutdownCtx)
+	}()
+
+	err = srv.Serve(ln)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
@@ -0,0 +1,149 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/action"
+	"github.com/devaloi/watchdog/internal/watcher"
+	"github.com/fsnotify/fsnotify"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+		t.Errorf("Content-Type = %q, want %q", ct, ContentType)
+	}
+
+	return rec.Body.String()
+}
+
+func TestExposition(t *testing.T) {
+	m := New()
+
+	m.ObserveEvent(watcher.Event{Type: watcher.Modify})
+	m.ObserveEvent(watcher.Event{Type: watcher.Modify})
+	m.ObserveEvent(watcher.Event{Type: watcher.Create})
+	m.ObserveFiltered()
+	m.ObserveMatch("build")
+	m.ObserveFire("build")
+	m.ObserveAction("build", "command", nil, time.Millisecond)
+	m.ObserveAction("notify", "webhook", errors.New("refused"), 20*time.Millisecond)
+	m.ObserveCommandExit("build", action.Result{Duration: 2 * time.Second})
+	m.ObserveCommandExit("build", action.Result{Duration: time.Second, Canceled: true})
+	m.ObserveWatchError(errors.New("boom"))
+	m.ObserveWatchError(fsnotify.ErrEventOverflow)
+	m.WatchedDirs.Set(func() float64 { return 7 })
+
+	out := scrape(t, m)
+
+	for _, want := range []string{
+		"# TYPE watchdog_events_total counter\n",
+		`watchdog_events_total{type="create"} 1` + "\n",
+		`watchdog_events_total{type="modify"} 2` + "\n",
+		"watchdog_events_filtered_total 1\n",
+		`watchdog_rule_matches_total{rule="build"} 1` + "\n",
+		`watchdog_debounced_fires_total{rule="build"} 1` + "\n",
+		`watchdog_actions_total{rule="build",action="command",result="success"} 1` + "\n",
+		`watchdog_actions_total{rule="notify",action="webhook",result="failure"} 1` + "\n",
+		"# TYPE watchdog_webhook_duration_seconds histogram\n",
+		`watchdog_webhook_duration_seconds_bucket{rule="notify",le="0.01"} 0` + "\n",
+		`watchdog_webhook_duration_seconds_bucket{rule="notify",le="0.025"} 1` + "\n",
+		`watchdog_webhook_duration_seconds_bucket{rule="notify",le="+Inf"} 1` + "\n",
+		`watchdog_webhook_duration_seconds_count{rule="notify"} 1` + "\n",
+		`watchdog_command_duration_seconds_sum{rule="build"} 2` + "\n",
+		`watchdog_command_duration_seconds_count{rule="build"} 1` + "\n",
+		"watchdog_watched_directories 7\n",
+		"watchdog_watch_errors_total 1\n",
+		"watchdog_watch_overflows_total 1\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestExpositionZeroValues(t *testing.T) {
+	out := scrape(t, New())
+
+	for _, want := range []string{
+		"watchdog_events_filtered_total 0\n",
+		"watchdog_watch_overflows_total 0\n",
+		"watchdog_watched_directories 0\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("missing %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	m := New()
+	m.ObserveMatch("say \"hi\"\\\n")
+
+	out := scrape(t, m)
+
+	want := `watchdog_rule_matches_total{rule="say \"hi\"\\\n"} 1`
+	if !strings.Contains(out, want) {
+		t.Errorf("missing %q in:\n%s", want, out)
+	}
+}
+
+func TestServe(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := ln.Addr().String()
+	_ = ln.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() { done <- New().Serve(ctx, addr) }()
+
+	var resp *http.Response
+
+	for range 50 {
+		resp, err = http.Get("http://" + addr + "/metrics") //nolint:noctx // test request
+		if err == nil {
+			break
+		}
+
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+
+	if !strings.Contains(string(body), "watchdog_events_total") {
+		t.Errorf("unexpected body:\n%s", body)
+	}
+
+	cancel()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Serve returned %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Serve did not return after cancel")
+	}
+}
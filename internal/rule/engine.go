@@ -16,32 +16,72 @@ type Match struct {
 }
 
 // Engine evaluates file system events against configured rules.
+// Patterns are compiled once so each event is matched in a single pass.
 type Engine struct {
-	rules         []config.Rule
-	globalIgnores []string
+	rules   []config.Rule
+	content []*matcher.ContentPredicate
+	watches *matcher.Set
+	ignores *matcher.Set
 }
 
-// NewEngine creates an Engine from a parsed config.
+// NewEngine creates an Engine from a parsed config, reusing the content
+// predicates config.Parse compiled. Like a watch pattern that does not
+// compile, a rule built in code with an invalid content predicate never
+// matches; config.Parse rejects both.
 func NewEngine(cfg *config.Config) *Engine {
+	rules := make([]config.Rule, 0, len(cfg.Rules))
+	content := make([]*matcher.ContentPredicate, 0, len(cfg.Rules))
+	watches := make([][]string, 0, len(cfg.Rules))
+
+	for _, r := range cfg.Rules {
+		p, err := r.ParsedContent()
+
+		rules = append(rules, r)
+		content = append(content, p)
+
+		if err != nil {
+			watches = append(watches, nil)
+
+			continue
+		}
+
+		watches = append(watches, r.Watch)
+	}
+
+	var opts []matcher.Option
+	if cfg.Global.CaseInsensitive {
+		opts = append(opts, matcher.IgnoreCase())
+	}
+
 	return &Engine{
-		rules:         cfg.Rules,
-		globalIgnores: cfg.Global.Ignore,
+		rules:   rules,
+		content: content,
+		watches: matcher.NewSet(watches, opts...),
+		ignores: matcher.NewSet([][]string{cfg.Global.Ignore}, opts...),
 	}
 }
 
 // Evaluate checks the event against all rules and returns matching actions.
 func (e *Engine) Evaluate(ev watcher.Event) []Match {
 	// Check global ignore patterns first
-	for _, ign := range e.globalIgnores {
-		if matcher.MatchPattern(ign, ev.Path) {
-			return nil
-		}
+	if e.ignores.MatchAny(ev.Path) {
+		return nil
 	}
 
 	var matches []Match
 
-	for _, r := range e.rules {
-		if !e.matchesRule(r, ev) {
+	for _, i := range e.watches.Match(ev.Path) {
+		r := e.rules[i]
+
+		if len(r.Events) > 0 && !containsEvent(r.Events, ev.Type) {
+			continue
+		}
+
+		if len(r.Events) == 0 && ev.Type.OptIn() {
+			continue
+		}
+
+		if e.content[i] != nil && !e.content[i].Match(ev.Path) {
 			continue
 		}
 
@@ -54,20 +94,6 @@ func (e *Engine) Evaluate(ev watcher.Event) []Match {
 	return matches
 }
 
-func (e *Engine) matchesRule(r config.Rule, ev watcher.Event) bool {
-	if len(r.Events) > 0 && !containsEvent(r.Events, ev.Type) {
-		return false
-	}
-
-	for _, pattern := range r.Watch {
-		if matcher.MatchPattern(pattern, ev.Path) {
-			return true
-		}
-	}
-
-	return false
-}
-
 func containsEvent(events []string, t watcher.EventType) bool {
 	return slices.Contains(events, string(t))
 }
@@ -1,6 +1,8 @@
 package rule
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/devaloi/watchdog/internal/config"
@@ -96,6 +98,31 @@ func TestEvaluateNoMatchingEvent(t *testing.T) {
 	}
 }
 
+func TestEvaluateOptInEvents(t *testing.T) {
+	cfg := &config.Config{
+		Rules: []config.Rule{
+			{Name: "any", Watch: []string{"**"}},
+			{Name: "resync", Watch: []string{"**"}, Events: []string{"resync"}},
+		},
+	}
+	eng := NewEngine(cfg)
+
+	matches := eng.Evaluate(watcher.Event{Path: ".", Type: watcher.Resync, Name: ".", Dir: "."})
+	if len(matches) != 1 || matches[0].RuleName != "resync" {
+		t.Errorf("resync matched %+v, want only the rule listing it", matches)
+	}
+
+	matches = eng.Evaluate(watcher.Event{Path: "a.go", Type: watcher.Chmod, Name: "a.go", Dir: "."})
+	if len(matches) != 0 {
+		t.Errorf("chmod matched %+v, want no rule", matches)
+	}
+
+	matches = eng.Evaluate(watcher.Event{Path: "a.go", Type: watcher.Modify, Name: "a.go", Dir: "."})
+	if len(matches) != 1 || matches[0].RuleName != "any" {
+		t.Errorf("modify matched %+v, want only the rule without events", matches)
+	}
+}
+
 func TestEvaluateNoMatch(t *testing.T) {
 	eng := NewEngine(testConfig())
 
@@ -118,3 +145,139 @@ func TestEvaluateNodeModulesIgnored(t *testing.T) {
 		t.Errorf("expected 0 matches for node_modules path, got %d", len(matches))
 	}
 }
+
+func TestEvaluateRegexPattern(t *testing.T) {
+	cfg := &config.Config{
+		Rules: []config.Rule{
+			{
+				Name:   "Migrations",
+				Watch:  []string{`re:^migrations/\d{4}_.*\.sql$`},
+				Action: config.Action{Type: "log", Format: "{{.Path}}"},
+			},
+		},
+	}
+
+	eng := NewEngine(cfg)
+
+	ev := watcher.Event{Path: "migrations/0042_users.sql", Type: watcher.Create, Name: "0042_users.sql", Dir: "migrations"}
+	if len(eng.Evaluate(ev)) != 1 {
+		t.Error("expected regex pattern to match migration file")
+	}
+
+	ev = watcher.Event{Path: "migrations/users.sql", Type: watcher.Create, Name: "users.sql", Dir: "migrations"}
+	if len(eng.Evaluate(ev)) != 0 {
+		t.Error("expected regex pattern to reject unnumbered migration")
+	}
+}
+
+func TestEvaluateNegatedPattern(t *testing.T) {
+	cfg := &config.Config{
+		Global: config.Global{
+			Ignore: []string{"build", "!build/keep.go"},
+		},
+		Rules: []config.Rule{
+			{
+				Name:   "Go sources",
+				Watch:  []string{"{cmd,internal}/**/*.go", "build/*.go", "!**/*_test.go"},
+				Action: config.Action{Type: "log", Format: "{{.Path}}"},
+			},
+		},
+	}
+
+	eng := NewEngine(cfg)
+
+	tests := []struct {
+		path string
+		want int
+	}{
+		{"cmd/app/main.go", 1},
+		{"internal/rule/engine_test.go", 0},
+		{"build/gen.go", 0},
+		{"build/keep.go", 1},
+	}
+
+	for _, tt := range tests {
+		got := len(eng.Evaluate(watcher.Event{Path: tt.path, Type: watcher.Modify}))
+		if got != tt.want {
+			t.Errorf("Evaluate(%q) matched %d rules, want %d", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateCaseInsensitive(t *testing.T) {
+	cfg := &config.Config{
+		Global: config.Global{
+			Ignore:          []string{"*.tmp"},
+			CaseInsensitive: true,
+		},
+		Rules: []config.Rule{
+			{
+				Name:   "Images",
+				Watch:  []string{"assets/**/*.png"},
+				Action: config.Action{Type: "log", Format: "{{.Path}}"},
+			},
+		},
+	}
+
+	eng := NewEngine(cfg)
+
+	if len(eng.Evaluate(watcher.Event{Path: "Assets/brand/Logo.PNG", Type: watcher.Create})) != 1 {
+		t.Error("expected case-insensitive match for Logo.PNG")
+	}
+
+	if len(eng.Evaluate(watcher.Event{Path: "CACHE.TMP", Type: watcher.Create})) != 0 {
+		t.Error("expected case-insensitive ignore for CACHE.TMP")
+	}
+}
+
+func TestEvaluateContentPredicate(t *testing.T) {
+	dir := t.TempDir()
+
+	draft := filepath.Join(dir, "draft.md")
+	published := filepath.Join(dir, "published.md")
+
+	for path, body := range map[string]string{
+		draft:     "---\ndraft: true\n---\n",
+		published: "---\ndraft: false\n---\n",
+	} {
+		writeErr := os.WriteFile(path, []byte(body), 0o600)
+		if writeErr != nil {
+			t.Fatal(writeErr)
+		}
+	}
+
+	cfg := &config.Config{
+		Rules: []config.Rule{
+			{
+				Name:     "Drafts",
+				Watch:    []string{"**/*.md"},
+				Contains: "draft: true",
+				Action:   config.Action{Type: "log", Format: "{{.Path}}"},
+			},
+		},
+	}
+
+	eng := NewEngine(cfg)
+
+	if len(eng.Evaluate(watcher.Event{Path: draft, Type: watcher.Modify})) != 1 {
+		t.Error("expected draft file to match content predicate")
+	}
+
+	if len(eng.Evaluate(watcher.Event{Path: published, Type: watcher.Modify})) != 0 {
+		t.Error("expected published file to be rejected by content predicate")
+	}
+}
+
+func TestNewEngineInvalidContentRegex(t *testing.T) {
+	eng := NewEngine(&config.Config{
+		Rules: []config.Rule{
+			{Name: "bad", Watch: []string{"*"}, ContentRegex: "("},
+			{Name: "good", Watch: []string{"*"}},
+		},
+	})
+
+	matches := eng.Evaluate(watcher.Event{Path: "a.go", Type: watcher.Modify})
+	if len(matches) != 1 || matches[0].RuleName != "good" {
+		t.Errorf("matches = %+v, want only the rule with a valid predicate", matches)
+	}
+}
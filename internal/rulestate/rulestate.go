@@ -0,0 +1,205 @@
+// Package rulestate tracks the lifecycle state of each rule from the
+// display.Printer calls watchdog makes as events match and actions run.
+// The terminal dashboard and the HTTP control API both report it.
+package rulestate
+
+import (
+	"sync"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/action"
+	"github.com/devaloi/watchdog/internal/config"
+	"github.com/devaloi/watchdog/internal/display"
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+// State is the lifecycle state of a rule.
+type State string
+
+// Rule states.
+const (
+	Idle       State = "idle"
+	Debouncing State = "debouncing"
+	Running    State = "running"
+	Failed     State = "failed"
+)
+
+// Status is the state of one rule. Finished, OK and Error describe the
+// last finished run; HasExit and ExitCode its command's exit, if any.
+type Status struct {
+	Name     string
+	Action   string
+	State    State
+	Paused   bool
+	LastRun  time.Time
+	Finished bool
+	OK       bool
+	Error    string
+	HasExit  bool
+	ExitCode int
+}
+
+// Tracker keeps every rule's Status. It implements display.Printer so it
+// can be fed the same calls as the output, and is safe for concurrent use.
+type Tracker struct {
+	now func() time.Time
+
+	mu     sync.Mutex
+	rules  []*Status
+	byName map[string]*Status
+}
+
+var _ display.Printer = (*Tracker)(nil)
+
+// New creates a Tracker that stamps runs with now.
+func New(now func() time.Time) *Tracker {
+	return &Tracker{now: now, byName: make(map[string]*Status)}
+}
+
+// Rules returns a snapshot of every rule's status in config order.
+func (t *Tracker) Rules() []Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]Status, 0, len(t.rules))
+	for _, st := range t.rules {
+		out = append(out, *st)
+	}
+
+	return out
+}
+
+// Rule returns the status of the named rule.
+func (t *Tracker) Rule(name string) (Status, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	st, ok := t.byName[name]
+	if !ok {
+		return Status{}, false
+	}
+
+	return *st, true
+}
+
+// SetPaused records the paused flag and returns the updated status.
+func (t *Tracker) SetPaused(name string, paused bool) (Status, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	st, ok := t.byName[name]
+	if !ok {
+		return Status{}, false
+	}
+
+	st.Paused = paused
+
+	return *st, true
+}
+
+// Banner registers the configured rules. On reload, rules that still
+// exist keep their state and removed rules are dropped.
+func (t *Tracker) Banner(cfg *config.Config, _ string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rules := make([]*Status, 0, len(cfg.Rules))
+	byName := make(map[string]*Status, len(cfg.Rules))
+
+	for _, r := range cfg.Rules {
+		st, ok := t.byName[r.Name]
+		if !ok {
+			st = &Status{Name: r.Name, State: Idle}
+		}
+
+		st.Action = r.Action.Type
+		rules = append(rules, st)
+		byName[r.Name] = st
+	}
+
+	t.rules = rules
+	t.byName = byName
+}
+
+// Event marks a matched rule as debouncing.
+func (t *Tracker) Event(_ watcher.Event, ruleName string) {
+	t.update(ruleName, func(st *Status) {
+		if st.State != Running {
+			st.State = Debouncing
+		}
+	})
+}
+
+// Verbose is a no-op; filtered events change no rule's state.
+func (t *Tracker) Verbose(watcher.Event, string) {}
+
+// ActionStart marks the rule as running.
+func (t *Tracker) ActionStart(ruleName, _ string, _ watcher.Event) {
+	t.update(ruleName, func(st *Status) {
+		st.State = Running
+		st.LastRun = t.now()
+	})
+}
+
+// ActionResult records the result of an action. Command actions stay
+// running until CommandExit.
+func (t *Tracker) ActionResult(ruleName string, err error, _ time.Duration) {
+	t.update(ruleName, func(st *Status) {
+		switch {
+		case err != nil:
+			st.finish(false, err.Error())
+		case st.Action != "command":
+			st.finish(true, "")
+		}
+	})
+}
+
+// CommandExit records the exit code of a finished command. Canceled runs
+// leave the state to the run that replaced them.
+func (t *Tracker) CommandExit(ruleName string, res action.Result) {
+	if res.Canceled {
+		return
+	}
+
+	t.update(ruleName, func(st *Status) {
+		st.HasExit = true
+		st.ExitCode = res.ExitCode
+
+		msg := ""
+		if res.Err != nil && res.ExitCode == 0 {
+			msg = res.Err.Error()
+		}
+
+		st.finish(res.ExitCode == 0 && res.Err == nil, msg)
+	})
+}
+
+// DryRun returns the rule to idle.
+func (t *Tracker) DryRun(ruleName, _ string) {
+	t.update(ruleName, func(st *Status) {
+		st.State = Idle
+	})
+}
+
+// Shutdown is a no-op.
+func (t *Tracker) Shutdown() {}
+
+func (t *Tracker) update(ruleName string, fn func(st *Status)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if st := t.byName[ruleName]; st != nil {
+		fn(st)
+	}
+}
+
+func (st *Status) finish(ok bool, msg string) {
+	st.Finished = true
+	st.OK = ok
+	st.Error = msg
+
+	st.State = Idle
+	if !ok {
+		st.State = Failed
+	}
+}
@@ -0,0 +1,113 @@
+package rulestate
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/action"
+	"github.com/devaloi/watchdog/internal/config"
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+var testTime = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+func testTracker() *Tracker {
+	t := New(func() time.Time { return testTime })
+
+	t.Banner(&config.Config{
+		Rules: []config.Rule{
+			{Name: "build", Action: config.Action{Type: "command"}},
+			{Name: "notify", Action: config.Action{Type: "webhook"}},
+		},
+	}, "watchdog.yaml")
+
+	return t
+}
+
+func TestTransitions(t *testing.T) {
+	tr := testTracker()
+	ev := watcher.Event{Path: "main.go", Type: watcher.Modify}
+
+	tr.Event(ev, "build")
+
+	if st, _ := tr.Rule("build"); st.State != Debouncing {
+		t.Errorf("after match state = %q, want debouncing", st.State)
+	}
+
+	tr.ActionStart("build", "command", ev)
+	tr.ActionResult("build", nil, time.Millisecond)
+	tr.Event(ev, "build")
+
+	if st, _ := tr.Rule("build"); st.State != Running || !st.LastRun.Equal(testTime) || st.Finished {
+		t.Errorf("started command = %+v, want running since the test time", st)
+	}
+
+	tr.CommandExit("build", action.Result{ExitCode: 2})
+
+	if st, _ := tr.Rule("build"); st.State != Failed || !st.Finished || st.OK || !st.HasExit || st.ExitCode != 2 {
+		t.Errorf("after exit 2 = %+v", st)
+	}
+
+	tr.ActionStart("notify", "webhook", ev)
+	tr.ActionResult("notify", errors.New("refused"), time.Millisecond)
+
+	if st, _ := tr.Rule("notify"); st.State != Failed || st.Error != "refused" {
+		t.Errorf("failed webhook = %+v", st)
+	}
+
+	tr.ActionResult("notify", nil, time.Millisecond)
+
+	if st, _ := tr.Rule("notify"); st.State != Idle || !st.OK || st.Error != "" {
+		t.Errorf("passing webhook = %+v", st)
+	}
+
+	tr.DryRun("notify", "webhook")
+
+	if st, _ := tr.Rule("notify"); st.State != Idle {
+		t.Errorf("dry run state = %q, want idle", st.State)
+	}
+}
+
+func TestCanceledExitKeepsRunning(t *testing.T) {
+	tr := testTracker()
+
+	tr.ActionStart("build", "command", watcher.Event{})
+	tr.CommandExit("build", action.Result{ExitCode: -1, Canceled: true})
+
+	if st, _ := tr.Rule("build"); st.State != Running || st.HasExit {
+		t.Errorf("after canceled exit = %+v, want still running", st)
+	}
+}
+
+func TestReloadAndPause(t *testing.T) {
+	tr := testTracker()
+
+	tr.ActionStart("build", "command", watcher.Event{})
+
+	if _, ok := tr.SetPaused("build", true); !ok {
+		t.Fatal("expected build to be known")
+	}
+
+	if _, ok := tr.SetPaused("missing", true); ok {
+		t.Error("expected an unknown rule to be reported")
+	}
+
+	tr.Banner(&config.Config{Rules: []config.Rule{
+		{Name: "lint", Action: config.Action{Type: "command"}},
+		{Name: "build", Action: config.Action{Type: "command"}},
+	}}, "watchdog.yaml")
+
+	rules := tr.Rules()
+	if len(rules) != 2 || rules[0].Name != "lint" || rules[0].State != Idle {
+		t.Fatalf("rules after reload = %+v", rules)
+	}
+
+	if rules[1].State != Running || !rules[1].Paused {
+		t.Errorf("build after reload = %+v, want its state kept", rules[1])
+	}
+
+	if _, ok := tr.Rule("notify"); ok {
+		t.Error("expected the removed rule to be dropped")
+	}
+}
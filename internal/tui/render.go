@@ -0,0 +1,176 @@
+package tui
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/devaloi/watchdog/internal/rulestate"
+)
+
+const (
+	colorReset  = "\033[0m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorRed    = "\033[31m"
+	colorCyan   = "\033[36m"
+	colorDim    = "\033[2m"
+	colorBold   = "\033[1m"
+	colorInvert = "\033[7m"
+
+	eventRows  = 6
+	scrollStep = 10
+	helpLine   = "↑/↓ select  t trigger  r restart  p pause  c clear  u/d scroll  q quit"
+)
+
+// frame renders the whole dashboard for a terminal of the given size.
+// Lines are separated by "\r\n" because the terminal is in raw mode.
+func (t *TUI) frame(width, height int) string {
+	rules := t.state.Rules()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var lines []string
+
+	lines = append(lines, colorBold+" 🐕 watchdog"+colorReset+colorDim+" — "+itoaCount(len(rules), "rule")+colorReset)
+	lines = append(lines, colorDim+pad("   RULE", 30)+pad("STATE", 14)+pad("LAST RUN", 11)+"EXIT"+colorReset)
+
+	for i, st := range rules {
+		marker := "  "
+		if i == t.selected {
+			marker = colorCyan + "▸ " + colorReset
+		}
+
+		lastRun := "—"
+		if !st.LastRun.IsZero() {
+			lastRun = st.LastRun.Format("15:04:05")
+		}
+
+		state := stateColor(st.State) + pad(string(st.State), 14) + colorReset
+		if st.Paused {
+			state = colorDim + pad("paused", 14) + colorReset
+		}
+
+		lines = append(lines, marker+" "+pad(st.Name, 28)+state+pad(lastRun, 11)+formatExit(st))
+	}
+
+	eventsHeight := min(eventRows, len(t.events))
+	outputHeight := height - len(lines) - eventsHeight - 3
+
+	var sel *ruleView
+
+	title := " output "
+
+	if len(rules) > 0 {
+		name := rules[min(t.selected, len(rules)-1)].Name
+		sel = t.views[name]
+		title = " output: " + name + " "
+	}
+
+	if sel != nil && sel.scroll > 0 {
+		title += "(+" + itoaCount(sel.scroll, "line") + " below) "
+	}
+
+	lines = append(lines, rule(title, width))
+
+	if outputHeight > 0 {
+		var out []string
+		if sel != nil {
+			end := len(sel.output) - sel.scroll
+			start := max(end-outputHeight, 0)
+			out = sel.output[start:end]
+		}
+
+		for i := range outputHeight {
+			if i < len(out) {
+				lines = append(lines, out[i])
+			} else {
+				lines = append(lines, "")
+			}
+		}
+	}
+
+	lines = append(lines, rule(" recent events ", width))
+	lines = append(lines, t.events[len(t.events)-eventsHeight:]...)
+	lines = append(lines, colorInvert+pad(" "+helpLine, width)+colorReset)
+
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+
+	var b strings.Builder
+
+	b.WriteString("\033[H")
+
+	for i, line := range lines {
+		b.WriteString(truncate(line, width))
+		b.WriteString("\033[K")
+
+		if i < len(lines)-1 {
+			b.WriteString("\r\n")
+		}
+	}
+
+	b.WriteString("\033[J")
+
+	return b.String()
+}
+
+func stateColor(s rulestate.State) string {
+	switch s {
+	case rulestate.Running:
+		return colorYellow
+	case rulestate.Debouncing:
+		return colorCyan
+	case rulestate.Failed:
+		return colorRed
+	default:
+		return colorGreen
+	}
+}
+
+func rule(title string, width int) string {
+	return colorDim + "──" + title + strings.Repeat("─", max(width-utf8.RuneCountInString(title)-2, 0)) + colorReset
+}
+
+func pad(s string, width int) string {
+	n := utf8.RuneCountInString(s)
+	if n >= width {
+		return string([]rune(s)[:max(width-1, 0)]) + " "
+	}
+
+	return s + strings.Repeat(" ", width-n)
+}
+
+// truncate cuts s to width visible runes, skipping over ANSI escapes.
+func truncate(s string, width int) string {
+	visible := 0
+	inEscape := false
+
+	for i, r := range s {
+		switch {
+		case r == '\033':
+			inEscape = true
+		case inEscape:
+			if r >= '@' && r <= '~' && r != '[' {
+				inEscape = false
+			}
+		default:
+			visible++
+			if visible > width {
+				return s[:i] + colorReset
+			}
+		}
+	}
+
+	return s
+}
+
+func itoaCount(n int, noun string) string {
+	if n != 1 {
+		noun += "s"
+	}
+
+	return strconv.Itoa(n) + " " + noun
+}
@@ -0,0 +1,137 @@
+package tui
+
+import (
+	"context"
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/term"
+)
+
+// Key names produced by parseKeys in addition to single printable characters.
+const (
+	keyUp       = "up"
+	keyDown     = "down"
+	keyPageUp   = "pgup"
+	keyPageDown = "pgdn"
+	keyQuit     = "q"
+	keyCtrlC    = "ctrl+c"
+)
+
+const (
+	redrawInterval = 50 * time.Millisecond
+	clockInterval  = time.Second
+)
+
+// Run puts the terminal into raw mode on the alternate screen and drives
+// the dashboard until ctx is canceled or the user quits. The terminal is
+// restored before Run returns.
+func (t *TUI) Run(ctx context.Context, in, out *os.File) error {
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = term.Restore(int(in.Fd()), state) }()
+
+	_, _ = io.WriteString(out, "\033[?1049h\033[?25l")
+	defer func() { _, _ = io.WriteString(out, "\033[?25h\033[?1049l") }()
+
+	keys := make(chan string, 16)
+
+	// The reader goroutine stays blocked on stdin after Run returns;
+	// that is harmless because the process is about to exit.
+	go readKeys(in, keys)
+
+	draw := func() {
+		width, height, sizeErr := term.GetSize(int(out.Fd()))
+		if sizeErr != nil {
+			width, height = 80, 24
+		}
+
+		_, _ = io.WriteString(out, t.frame(width, height))
+	}
+
+	draw()
+
+	throttle := time.NewTicker(redrawInterval)
+	defer throttle.Stop()
+
+	clock := time.NewTicker(clockInterval)
+	defer clock.Stop()
+
+	pending := false
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-t.quit:
+			return nil
+		case key := <-keys:
+			t.HandleKey(key)
+		case <-t.dirty:
+			pending = true
+		case <-throttle.C:
+			if pending {
+				pending = false
+
+				draw()
+			}
+		case <-clock.C:
+			draw()
+		}
+	}
+}
+
+func readKeys(in io.Reader, keys chan<- string) {
+	buf := make([]byte, 64)
+
+	for {
+		n, err := in.Read(buf)
+		if err != nil {
+			return
+		}
+
+		for _, k := range parseKeys(buf[:n]) {
+			keys <- k
+		}
+	}
+}
+
+// parseKeys splits raw terminal input into key names.
+func parseKeys(buf []byte) []string {
+	var keys []string
+
+	for i := 0; i < len(buf); i++ {
+		switch {
+		case buf[i] == 0x03:
+			keys = append(keys, keyCtrlC)
+		case buf[i] == 0x1b && i+2 < len(buf) && buf[i+1] == '[':
+			seq := buf[i+2]
+			i += 2
+
+			switch seq {
+			case 'A':
+				keys = append(keys, keyUp)
+			case 'B':
+				keys = append(keys, keyDown)
+			case '5', '6':
+				if i+1 < len(buf) && buf[i+1] == '~' {
+					i++
+
+					if seq == '5' {
+						keys = append(keys, keyPageUp)
+					} else {
+						keys = append(keys, keyPageDown)
+					}
+				}
+			}
+		case buf[i] >= 0x20 && buf[i] < 0x7f:
+			keys = append(keys, string(buf[i]))
+		}
+	}
+
+	return keys
+}
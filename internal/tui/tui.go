@@ -0,0 +1,318 @@
+// Package tui implements an interactive terminal dashboard showing rule
+// state, per-rule command output and recent events.
+package tui
+
+import (
+	"bytes"
+	"io"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/action"
+	"github.com/devaloi/watchdog/internal/config"
+	"github.com/devaloi/watchdog/internal/display"
+	"github.com/devaloi/watchdog/internal/rulestate"
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+const (
+	maxOutputLines = 1000
+	maxEvents      = 200
+)
+
+// Controller performs the actions bound to dashboard keys.
+type Controller interface {
+	// Trigger runs the rule's action now, bypassing debounce.
+	Trigger(rule string)
+	// Restart kills the rule's running command, if any, and runs it again.
+	Restart(rule string)
+	// SetPaused stops or resumes reacting to events for the rule.
+	SetPaused(rule string, paused bool)
+}
+
+// ruleView is the output pane of one rule; its state is kept by the
+// rulestate.Tracker.
+type ruleView struct {
+	output  []string
+	partial []byte
+	scroll  int
+}
+
+// TUI is the dashboard model. It implements display.Printer so it can
+// replace the line-based output, and is safe for concurrent use.
+type TUI struct {
+	ctl   Controller
+	now   func() time.Time
+	state *rulestate.Tracker
+
+	mu       sync.Mutex
+	views    map[string]*ruleView
+	events   []string
+	selected int
+	dirty    chan struct{}
+	quit     chan struct{}
+	quitOnce sync.Once
+}
+
+var _ display.Printer = (*TUI)(nil)
+
+// New creates a TUI that sends key-bound actions to ctl.
+func New(ctl Controller) *TUI {
+	t := &TUI{
+		ctl:   ctl,
+		now:   time.Now,
+		views: make(map[string]*ruleView),
+		dirty: make(chan struct{}, 1),
+		quit:  make(chan struct{}),
+	}
+
+	t.state = rulestate.New(func() time.Time { return t.now() })
+
+	return t
+}
+
+// Done is closed when the user quits the dashboard.
+func (t *TUI) Done() <-chan struct{} {
+	return t.quit
+}
+
+// RuleOutput returns a writer that feeds the named rule's output pane,
+// suitable for CommandAction.Output.
+func (t *TUI) RuleOutput(name string) io.Writer {
+	return &ruleWriter{t: t, name: name}
+}
+
+// Banner registers the configured rules. On reload, rules that still
+// exist keep their state and output and removed rules are dropped.
+func (t *TUI) Banner(cfg *config.Config, configPath string) {
+	t.state.Banner(cfg, configPath)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	views := make(map[string]*ruleView, len(cfg.Rules))
+
+	for _, r := range cfg.Rules {
+		rv, ok := t.views[r.Name]
+		if !ok {
+			rv = &ruleView{}
+		}
+
+		views[r.Name] = rv
+	}
+
+	t.views = views
+	t.selected = max(min(t.selected, len(cfg.Rules)-1), 0)
+	t.markDirty()
+}
+
+// Event records an event; a matched rule enters the debouncing state.
+func (t *TUI) Event(ev watcher.Event, ruleName string) {
+	t.state.Event(ev, ruleName)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	line := t.now().Format("15:04:05") + " " + string(ev.Type) + " " + ev.Path
+	if ruleName != "" {
+		line += " → " + ruleName
+	}
+
+	t.addEvent(line)
+	t.markDirty()
+}
+
+// Verbose records a filtered event.
+func (t *TUI) Verbose(ev watcher.Event, reason string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.addEvent(t.now().Format("15:04:05") + " [filtered] " + string(ev.Type) + " " + ev.Path + " (" + reason + ")")
+	t.markDirty()
+}
+
+// ActionStart marks the rule as running.
+func (t *TUI) ActionStart(ruleName, actionType string, ev watcher.Event) {
+	t.state.ActionStart(ruleName, actionType, ev)
+	t.redraw()
+}
+
+// ActionResult records the result of an action and shows an error in the
+// rule's output pane.
+func (t *TUI) ActionResult(ruleName string, err error, elapsed time.Duration) {
+	t.state.ActionResult(ruleName, err, elapsed)
+	t.update(ruleName, func(rv *ruleView) {
+		if err != nil {
+			rv.appendOutput([]byte("error: " + err.Error() + "\n"))
+		}
+	})
+}
+
+// CommandExit records the exit code of a finished command.
+func (t *TUI) CommandExit(ruleName string, res action.Result) {
+	t.state.CommandExit(ruleName, res)
+	t.redraw()
+}
+
+// DryRun records a skipped action as an event.
+func (t *TUI) DryRun(ruleName, actionType string) {
+	t.state.DryRun(ruleName, actionType)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.addEvent(t.now().Format("15:04:05") + " [dry run] " + ruleName + " (" + actionType + ")")
+	t.markDirty()
+}
+
+// Shutdown is a no-op; the dashboard is torn down when Run returns.
+func (t *TUI) Shutdown() {}
+
+// HandleKey applies a key from parseKeys to the dashboard.
+func (t *TUI) HandleKey(key string) {
+	if key == keyQuit || key == keyCtrlC {
+		t.quitOnce.Do(func() { close(t.quit) })
+
+		return
+	}
+
+	rules := t.state.Rules()
+
+	t.mu.Lock()
+
+	if len(rules) == 0 {
+		t.mu.Unlock()
+
+		return
+	}
+
+	st := rules[min(t.selected, len(rules)-1)]
+	name := st.Name
+
+	rv := t.views[name]
+	if rv == nil {
+		rv = &ruleView{}
+	}
+
+	var run func()
+
+	switch key {
+	case keyUp, "k":
+		t.selected = max(t.selected-1, 0)
+	case keyDown, "j":
+		t.selected = min(t.selected+1, len(rules)-1)
+	case keyPageUp, "u":
+		rv.scroll = min(rv.scroll+scrollStep, max(len(rv.output)-1, 0))
+	case keyPageDown, "d":
+		rv.scroll = max(rv.scroll-scrollStep, 0)
+	case "c":
+		rv.output = nil
+		rv.partial = nil
+		rv.scroll = 0
+	case "t":
+		run = func() { t.ctl.Trigger(name) }
+	case "r":
+		run = func() { t.ctl.Restart(name) }
+	case "p":
+		paused := !st.Paused
+		t.state.SetPaused(name, paused)
+		run = func() { t.ctl.SetPaused(name, paused) }
+	}
+
+	t.markDirty()
+	t.mu.Unlock()
+
+	// Controllers may report back through the Printer methods, so call
+	// them without holding the lock.
+	if run != nil && t.ctl != nil {
+		run()
+	}
+}
+
+func (t *TUI) update(ruleName string, fn func(rv *ruleView)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	rv := t.views[ruleName]
+	if rv == nil {
+		return
+	}
+
+	fn(rv)
+	t.markDirty()
+}
+
+func (t *TUI) addEvent(line string) {
+	t.events = append(t.events, line)
+	if len(t.events) > maxEvents {
+		t.events = t.events[len(t.events)-maxEvents:]
+	}
+}
+
+// redraw requests a redraw after a change to the tracked state.
+func (t *TUI) redraw() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.markDirty()
+}
+
+// markDirty requests a redraw. Callers must hold t.mu.
+func (t *TUI) markDirty() {
+	select {
+	case t.dirty <- struct{}{}:
+	default:
+	}
+}
+
+// ansiEscape matches terminal control sequences, which are stripped from
+// command output so they cannot corrupt the layout.
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\a]*\a`)
+
+func (rv *ruleView) appendOutput(p []byte) {
+	data := append(rv.partial, p...)
+
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+
+		line := bytes.TrimRight(data[:i], "\r")
+		rv.output = append(rv.output, string(ansiEscape.ReplaceAll(line, nil)))
+		data = data[i+1:]
+
+		if rv.scroll > 0 {
+			rv.scroll++
+		}
+	}
+
+	rv.partial = append([]byte(nil), data...)
+
+	if len(rv.output) > maxOutputLines {
+		rv.output = rv.output[len(rv.output)-maxOutputLines:]
+	}
+}
+
+type ruleWriter struct {
+	t    *TUI
+	name string
+}
+
+func (w *ruleWriter) Write(p []byte) (int, error) {
+	w.t.update(w.name, func(rv *ruleView) {
+		rv.appendOutput(p)
+	})
+
+	return len(p), nil
+}
+
+func formatExit(st rulestate.Status) string {
+	if !st.HasExit {
+		return "—"
+	}
+
+	return strconv.Itoa(st.ExitCode)
+}
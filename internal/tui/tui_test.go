@@ -0,0 +1,180 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/config"
+	"github.com/devaloi/watchdog/internal/rulestate"
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+type fakeController struct {
+	calls []string
+}
+
+func (f *fakeController) Trigger(rule string) { f.calls = append(f.calls, "trigger "+rule) }
+func (f *fakeController) Restart(rule string) { f.calls = append(f.calls, "restart "+rule) }
+
+func (f *fakeController) SetPaused(rule string, paused bool) {
+	f.calls = append(f.calls, fmt.Sprintf("paused %s %v", rule, paused))
+}
+
+func testTUI() (*TUI, *fakeController) {
+	ctl := &fakeController{}
+	t := New(ctl)
+	t.now = func() time.Time { return time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC) }
+
+	t.Banner(&config.Config{
+		Rules: []config.Rule{
+			{Name: "build", Action: config.Action{Type: "command"}},
+			{Name: "notify", Action: config.Action{Type: "webhook"}},
+		},
+	}, "watchdog.yaml")
+
+	return t, ctl
+}
+
+func TestRuleStateTransitions(t *testing.T) {
+	ui, _ := testTUI()
+	ev := watcher.Event{Path: "main.go", Type: watcher.Modify}
+
+	ui.Event(ev, "build")
+
+	if st, _ := ui.state.Rule("build"); st.State != rulestate.Debouncing {
+		t.Errorf("after match state = %q, want debouncing", st.State)
+	}
+
+	ui.ActionStart("notify", "webhook", ev)
+	ui.ActionResult("notify", errors.New("refused"), time.Millisecond)
+
+	if st, _ := ui.state.Rule("notify"); st.State != rulestate.Failed {
+		t.Errorf("failed webhook state = %q, want failed", st.State)
+	}
+
+	if got := ui.views["notify"].output; !slices.Equal(got, []string{"error: refused"}) {
+		t.Errorf("notify output = %q, want the error", got)
+	}
+
+	if !strings.Contains(ui.frame(80, 20), "failed") {
+		t.Error("frame should show the failed state")
+	}
+}
+
+func TestReloadDropsRemovedRules(t *testing.T) {
+	ui, _ := testTUI()
+
+	ui.HandleKey(keyDown)
+	_, _ = io.WriteString(ui.RuleOutput("build"), "kept\n")
+
+	ui.Banner(&config.Config{Rules: []config.Rule{{Name: "build", Action: config.Action{Type: "command"}}}}, "watchdog.yaml")
+
+	if ui.selected != 0 {
+		t.Errorf("selected = %d, want 0 after the selected rule was removed", ui.selected)
+	}
+
+	if _, ok := ui.views["notify"]; ok {
+		t.Error("expected the removed rule's view to be dropped")
+	}
+
+	if got := ui.views["build"].output; !slices.Equal(got, []string{"kept"}) {
+		t.Errorf("build output = %q, want it kept across reload", got)
+	}
+}
+
+func TestRuleOutputLines(t *testing.T) {
+	ui, _ := testTUI()
+	w := ui.RuleOutput("build")
+
+	_, _ = io.WriteString(w, "ok  \033[32mpkg\033[0m\npartial")
+	_, _ = io.WriteString(w, " line\r\n")
+
+	got := ui.views["build"].output
+	want := []string{"ok  pkg", "partial line"}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestHandleKeys(t *testing.T) {
+	ui, ctl := testTUI()
+
+	ui.HandleKey("t")
+	ui.HandleKey(keyDown)
+	ui.HandleKey("r")
+	ui.HandleKey("p")
+	ui.HandleKey("p")
+	ui.HandleKey(keyUp)
+
+	want := []string{"trigger build", "restart notify", "paused notify true", "paused notify false"}
+	if !slices.Equal(ctl.calls, want) {
+		t.Errorf("controller calls = %q, want %q", ctl.calls, want)
+	}
+
+	if ui.selected != 0 {
+		t.Errorf("selected = %d, want 0", ui.selected)
+	}
+
+	_, _ = io.WriteString(ui.RuleOutput("build"), "line\n")
+	ui.HandleKey("c")
+
+	if len(ui.views["build"].output) != 0 {
+		t.Error("expected c to clear the selected rule's output")
+	}
+
+	ui.HandleKey(keyQuit)
+
+	select {
+	case <-ui.Done():
+	default:
+		t.Error("expected q to close Done")
+	}
+}
+
+func TestFrameLayout(t *testing.T) {
+	ui, _ := testTUI()
+
+	for i := range 30 {
+		_, _ = fmt.Fprintf(ui.RuleOutput("build"), "line %d\n", i)
+	}
+
+	ui.Event(watcher.Event{Path: "main.go", Type: watcher.Modify}, "build")
+
+	frame := ui.frame(80, 20)
+	rows := strings.Split(frame, "\r\n")
+
+	if len(rows) != 20 {
+		t.Errorf("frame has %d rows, want 20", len(rows))
+	}
+
+	for _, want := range []string{"build", "notify", "debouncing", "line 29", "main.go → build", "q quit"} {
+		if !strings.Contains(frame, want) {
+			t.Errorf("frame missing %q", want)
+		}
+	}
+
+	if strings.Contains(frame, "line 0\033") {
+		t.Error("expected oldest output lines to scroll out of view")
+	}
+
+	ui.HandleKey("u")
+
+	if !strings.Contains(ui.frame(80, 20), "below") {
+		t.Error("expected scrolled pane to show lines below indicator")
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	got := parseKeys([]byte("j\x1b[A\x1b[B\x1b[5~\x1b[6~q\x03"))
+	want := []string{"j", keyUp, keyDown, keyPageUp, keyPageDown, keyQuit, keyCtrlC}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("parseKeys = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,130 @@
+package watcher
+
+import (
+	"time"
+
+	"github.com/devaloi/watchdog/internal/matcher"
+)
+
+// DefaultTempPatterns match the temporary and backup files editors write
+// while saving: Vim swap and backup files and its 4913 write test, Emacs
+// lock and autosave files, JetBrains safe-write files, Kate swap files and
+// Chromium-based editors' swap files.
+var DefaultTempPatterns = []string{
+	"*~",
+	".*.swp",
+	".*.swx",
+	".*.swo",
+	"4913",
+	".#*",
+	"#*#",
+	"*___jb_tmp___",
+	"*___jb_old___",
+	".*.kate-swp",
+	"*.crswap",
+}
+
+// saveWindow is how long a delete or rename is held back waiting for the
+// path to be recreated, which turns the pair into a modify.
+const saveWindow = 50 * time.Millisecond
+
+// saves collapses the event sequences editors produce when saving through
+// a temporary file: events for temporary files are dropped, and a delete or
+// rename of a file followed shortly by its recreation becomes one modify.
+type saves struct {
+	temps *matcher.Set
+	held  map[string]heldEvent
+	queue []heldEvent // in deadline order; entries no longer in held are stale
+}
+
+type heldEvent struct {
+	ev       Event
+	deadline time.Time
+}
+
+func newSaves(patterns []string) *saves {
+	if len(patterns) == 0 {
+		return nil
+	}
+
+	return &saves{
+		temps: matcher.NewSet([][]string{patterns}),
+		held:  make(map[string]heldEvent),
+	}
+}
+
+// filter returns the events to deliver now for ev. A nil saves passes
+// every event through.
+func (s *saves) filter(ev Event, now time.Time) []Event {
+	if s == nil || ev.Type == Resync {
+		return []Event{ev}
+	}
+
+	if s.temps.MatchAny(ev.Name) {
+		return nil
+	}
+
+	switch ev.Type {
+	case Delete, Rename:
+		h := heldEvent{ev: ev, deadline: now.Add(saveWindow)}
+		s.held[ev.Path] = h
+		s.queue = append(s.queue, h)
+
+		return nil
+	case Create, Modify:
+		if _, ok := s.held[ev.Path]; ok {
+			delete(s.held, ev.Path)
+
+			ev.Type = Modify
+		}
+	}
+
+	return []Event{ev}
+}
+
+// expire returns the held events whose window has passed, in the order
+// they arrived.
+func (s *saves) expire(now time.Time) []Event {
+	if s == nil {
+		return nil
+	}
+
+	var events []Event
+
+	for len(s.queue) > 0 && !now.Before(s.queue[0].deadline) {
+		h := s.queue[0]
+		s.queue = s.queue[1:]
+
+		if s.current(h) {
+			delete(s.held, h.ev.Path)
+			events = append(events, h.ev)
+		}
+	}
+
+	return events
+}
+
+// next returns the earliest deadline of a held event.
+func (s *saves) next() (time.Time, bool) {
+	if s == nil {
+		return time.Time{}, false
+	}
+
+	for len(s.queue) > 0 && !s.current(s.queue[0]) {
+		s.queue = s.queue[1:]
+	}
+
+	if len(s.queue) == 0 {
+		return time.Time{}, false
+	}
+
+	return s.queue[0].deadline, true
+}
+
+// current reports whether a queued entry is still the held event for its
+// path, rather than one since released or held again.
+func (s *saves) current(h heldEvent) bool {
+	cur, ok := s.held[h.ev.Path]
+
+	return ok && cur.deadline.Equal(h.deadline) && cur.ev.Type == h.ev.Type
+}
@@ -0,0 +1,103 @@
+package watcher
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSavesFilter(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+
+	tests := []struct {
+		name   string
+		events []Event
+		want   []string
+	}{
+		{
+			name: "vim backup rename",
+			events: []Event{
+				newEvent("main.go", Rename),
+				newEvent("main.go~", Create),
+				newEvent("main.go", Create),
+				newEvent("main.go", Modify),
+				newEvent("main.go~", Delete),
+			},
+			want: []string{"modify main.go", "modify main.go"},
+		},
+		{
+			name: "vim swap and write test",
+			events: []Event{
+				newEvent("src/.main.go.swp", Modify),
+				newEvent("src/4913", Create),
+				newEvent("src/4913", Delete),
+				newEvent("src/main.go", Modify),
+			},
+			want: []string{"modify src/main.go"},
+		},
+		{
+			name: "jetbrains safe write",
+			events: []Event{
+				newEvent("App.kt___jb_tmp___", Create),
+				newEvent("App.kt___jb_tmp___", Modify),
+				newEvent("App.kt", Rename),
+				newEvent("App.kt___jb_old___", Create),
+				newEvent("App.kt___jb_tmp___", Rename),
+				newEvent("App.kt", Create),
+				newEvent("App.kt___jb_old___", Delete),
+			},
+			want: []string{"modify App.kt"},
+		},
+		{
+			name:   "plain delete is released after the window",
+			events: []Event{newEvent("old.go", Delete)},
+			want:   []string{"delete old.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newSaves(DefaultTempPatterns)
+
+			var got []string
+
+			record := func(events []Event) {
+				for _, ev := range events {
+					got = append(got, string(ev.Type)+" "+ev.Path)
+				}
+			}
+
+			for i, ev := range tt.events {
+				record(s.filter(ev, t0.Add(time.Duration(i)*time.Millisecond)))
+			}
+
+			held := slices.ContainsFunc(tt.want, func(e string) bool {
+				return strings.HasPrefix(e, "delete ")
+			})
+
+			if _, ok := s.next(); ok != held {
+				t.Errorf("next() reported a held event = %v", ok)
+			}
+
+			record(s.expire(t0.Add(time.Second)))
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("events = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSavesDisabled(t *testing.T) {
+	var s *saves
+
+	got := s.filter(newEvent("a.go~", Delete), time.Now())
+	if len(got) != 1 {
+		t.Errorf("nil saves should pass events through, got %v", got)
+	}
+
+	if newSaves(nil) != nil {
+		t.Error("no patterns should disable collapsing")
+	}
+}
@@ -0,0 +1,152 @@
+package watcher
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Clock is the time source used by Debouncer. Tests substitute a fake
+// clock to fire timers deterministically.
+type Clock interface {
+	Now() time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer is a pending AfterFunc call.
+type Timer interface {
+	// Stop prevents the call from running and reports whether it did so.
+	Stop() bool
+}
+
+// RealClock is the Clock backed by the time package.
+type RealClock struct{}
+
+// Now returns the current time.
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// AfterFunc calls f on its own goroutine after d.
+func (RealClock) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// ManualClock is a Clock whose time only moves when Advance is called.
+// Due timers run synchronously on the caller's goroutine, which makes it
+// suitable for tests and for replaying recorded events on a virtual
+// timeline.
+type ManualClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	seq    uint64
+	timers []*manualTimer
+}
+
+type manualTimer struct {
+	clock *ManualClock
+	when  time.Time
+	seq   uint64
+	fn    func()
+}
+
+var _ Clock = (*ManualClock)(nil)
+
+// NewManualClock creates a ManualClock set to start.
+func NewManualClock(start time.Time) *ManualClock {
+	return &ManualClock{now: start}
+}
+
+// Now returns the clock's current time.
+func (c *ManualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// AfterFunc schedules f to run when the clock is advanced past d. f never
+// runs inside AfterFunc itself, even when d is zero.
+func (c *ManualClock) AfterFunc(d time.Duration, f func()) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.seq++
+	t := &manualTimer{clock: c, when: c.now.Add(d), seq: c.seq, fn: f}
+	c.timers = append(c.timers, t)
+
+	return t
+}
+
+// Advance moves the clock forward by d, running every timer that becomes
+// due in order of its deadline. Timers scheduled by those callbacks run too
+// if they fall within d.
+func (c *ManualClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	end := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+
+		next := c.nextDue(end)
+		if next == nil {
+			c.now = end
+			c.mu.Unlock()
+
+			return
+		}
+
+		c.now = next.when
+		c.remove(next)
+		c.mu.Unlock()
+
+		next.fn()
+	}
+}
+
+// Pending returns the number of scheduled timers that have not run.
+func (c *ManualClock) Pending() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+// nextDue returns the earliest timer due by end. Callers must hold c.mu.
+func (c *ManualClock) nextDue(end time.Time) *manualTimer {
+	var next *manualTimer
+
+	for _, t := range c.timers {
+		if t.when.After(end) {
+			continue
+		}
+
+		if next == nil || t.when.Before(next.when) || (t.when.Equal(next.when) && t.seq < next.seq) {
+			next = t
+		}
+	}
+
+	return next
+}
+
+// remove drops t from the schedule and reports whether it was pending.
+// Callers must hold c.mu.
+func (c *ManualClock) remove(t *manualTimer) bool {
+	i := slices.Index(c.timers, t)
+	if i < 0 {
+		return false
+	}
+
+	c.timers = slices.Delete(c.timers, i, i+1)
+
+	return true
+}
+
+// Stop cancels the timer.
+func (t *manualTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	return t.clock.remove(t)
+}
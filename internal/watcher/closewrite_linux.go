@@ -0,0 +1,146 @@
+//go:build linux
+
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// closeWatcher reports IN_CLOSE_WRITE, which fsnotify does not expose,
+// from its own inotify instance watching the same directories.
+type closeWatcher struct {
+	file   *os.File
+	fd     int
+	paths  chan string
+	mu     sync.Mutex
+	wds    map[string]int
+	dirs   map[int]string
+	closed chan struct{}
+	wg     sync.WaitGroup
+}
+
+func newCloseWatcher() (*closeWatcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &closeWatcher{
+		// A non-blocking fd lets the runtime poller wake Read on Close.
+		file:   os.NewFile(uintptr(fd), "inotify-close-write"),
+		fd:     fd,
+		paths:  make(chan string, 128),
+		wds:    make(map[string]int),
+		dirs:   make(map[int]string),
+		closed: make(chan struct{}),
+	}
+
+	c.wg.Add(1)
+
+	go c.read()
+
+	return c, nil
+}
+
+func (c *closeWatcher) add(dir string) error {
+	if c == nil {
+		return nil
+	}
+
+	wd, err := unix.InotifyAddWatch(c.fd, dir, unix.IN_CLOSE_WRITE|unix.IN_ONLYDIR)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.wds[dir] = wd
+	c.dirs[wd] = dir
+
+	return nil
+}
+
+func (c *closeWatcher) remove(dir string) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	wd, ok := c.wds[dir]
+	if !ok {
+		return
+	}
+
+	delete(c.wds, dir)
+	delete(c.dirs, wd)
+
+	_, _ = unix.InotifyRmWatch(c.fd, uint32(wd)) //nolint:gosec // watch descriptors are non-negative
+}
+
+// events returns the paths of files closed after writing; nil for a nil
+// closeWatcher, so a select on it never fires.
+func (c *closeWatcher) events() <-chan string {
+	if c == nil {
+		return nil
+	}
+
+	return c.paths
+}
+
+func (c *closeWatcher) close() {
+	if c == nil {
+		return
+	}
+
+	close(c.closed)
+	_ = c.file.Close()
+	c.wg.Wait()
+}
+
+func (c *closeWatcher) read() {
+	defer c.wg.Done()
+
+	var buf [unix.SizeofInotifyEvent * 256]byte
+
+	for {
+		n, err := c.file.Read(buf[:])
+		if err != nil {
+			return
+		}
+
+		for offset := 0; offset+unix.SizeofInotifyEvent <= n; {
+			raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[offset])) //nolint:gosec // inotify event layout
+
+			end := offset + unix.SizeofInotifyEvent + int(raw.Len)
+			name := strings.TrimRight(string(buf[offset+unix.SizeofInotifyEvent:end]), "\x00")
+			offset = end
+
+			if raw.Mask&unix.IN_CLOSE_WRITE == 0 || name == "" {
+				continue
+			}
+
+			c.mu.Lock()
+			dir, ok := c.dirs[int(raw.Wd)]
+			c.mu.Unlock()
+
+			if !ok {
+				continue
+			}
+
+			select {
+			case c.paths <- filepath.Join(dir, name):
+			case <-c.closed:
+				return
+			}
+		}
+	}
+}
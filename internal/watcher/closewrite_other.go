@@ -0,0 +1,20 @@
+//go:build !linux
+
+package watcher
+
+import "errors"
+
+// closeWatcher is unavailable outside Linux; close_write needs inotify.
+type closeWatcher struct{}
+
+func newCloseWatcher() (*closeWatcher, error) {
+	return nil, errors.New("watcher: close_write events require Linux")
+}
+
+func (c *closeWatcher) add(string) error { return nil }
+
+func (c *closeWatcher) remove(string) {}
+
+func (c *closeWatcher) events() <-chan string { return nil }
+
+func (c *closeWatcher) close() {}
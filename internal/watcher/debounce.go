@@ -9,17 +9,32 @@ import (
 // only after no new events arrive within the configured delay.
 type Debouncer struct {
 	delay   time.Duration
+	clock   Clock
 	mu      sync.Mutex
-	timers  map[string]*time.Timer
+	timers  map[string]pendingTimer
+	gen     uint64
 	done    chan struct{}
 	doneOne sync.Once
 }
 
+// pendingTimer tags a timer with a generation so a callback that already
+// started when its timer was replaced can tell it is stale.
+type pendingTimer struct {
+	timer Timer
+	gen   uint64
+}
+
 // NewDebouncer creates a Debouncer with the given default delay.
 func NewDebouncer(delay time.Duration) *Debouncer {
+	return NewDebouncerWithClock(delay, RealClock{})
+}
+
+// NewDebouncerWithClock creates a Debouncer whose timers run on clock.
+func NewDebouncerWithClock(delay time.Duration, clock Clock) *Debouncer {
 	return &Debouncer{
 		delay:  delay,
-		timers: make(map[string]*time.Timer),
+		clock:  clock,
+		timers: make(map[string]pendingTimer),
 		done:   make(chan struct{}),
 	}
 }
@@ -43,11 +58,22 @@ func (d *Debouncer) TriggerWithDelay(key string, delay time.Duration, fn func())
 	}
 
 	if existing, ok := d.timers[key]; ok {
-		existing.Stop()
+		existing.timer.Stop()
 	}
 
-	d.timers[key] = time.AfterFunc(delay, func() {
+	d.gen++
+	gen := d.gen
+
+	timer := d.clock.AfterFunc(delay, func() {
 		d.mu.Lock()
+
+		current, ok := d.timers[key]
+		if !ok || current.gen != gen {
+			d.mu.Unlock()
+
+			return
+		}
+
 		delete(d.timers, key)
 		d.mu.Unlock()
 
@@ -58,6 +84,28 @@ func (d *Debouncer) TriggerWithDelay(key string, delay time.Duration, fn func())
 			fn()
 		}
 	})
+
+	d.timers[key] = pendingTimer{timer: timer, gen: gen}
+}
+
+// Cancel stops the pending timers whose keys match and returns how many
+// were canceled. Callers use it to drop work for paths that no longer
+// exist, such as files inside a deleted directory.
+func (d *Debouncer) Cancel(match func(key string) bool) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	n := 0
+
+	for key, pending := range d.timers {
+		if match(key) {
+			pending.timer.Stop()
+			delete(d.timers, key)
+			n++
+		}
+	}
+
+	return n
 }
 
 // Stop cancels all pending timers.
@@ -69,8 +117,8 @@ func (d *Debouncer) Stop() {
 	d.mu.Lock()
 	defer d.mu.Unlock()
 
-	for key, timer := range d.timers {
-		timer.Stop()
+	for key, pending := range d.timers {
+		pending.timer.Stop()
 		delete(d.timers, key)
 	}
 }
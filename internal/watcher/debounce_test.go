@@ -1,55 +1,61 @@
-package watcher
+package watcher_test
 
 import (
-	"sync"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/devaloi/watchdog/internal/watcher"
+	"github.com/devaloi/watchdog/watchdogtest"
 )
 
+func newFakeDebouncer(delay time.Duration) (*watcher.Debouncer, *watchdogtest.Clock) {
+	clock := watchdogtest.NewClock(watchdogtest.Epoch)
+
+	return watcher.NewDebouncerWithClock(delay, clock), clock
+}
+
 func TestDebouncerSingleFire(t *testing.T) {
-	d := NewDebouncer(50 * time.Millisecond)
+	d, clock := newFakeDebouncer(50 * time.Millisecond)
 	defer d.Stop()
 
-	var count atomic.Int32
+	count := 0
 
 	for range 10 {
 		d.Trigger("main.go", func() {
-			count.Add(1)
+			count++
 		})
 
-		time.Sleep(5 * time.Millisecond)
+		clock.Advance(5 * time.Millisecond)
 	}
 
-	time.Sleep(100 * time.Millisecond)
+	if count != 0 {
+		t.Fatalf("fired %d times before the delay elapsed", count)
+	}
+
+	clock.Advance(50 * time.Millisecond)
 
-	if got := count.Load(); got != 1 {
-		t.Errorf("expected 1 fire, got %d", got)
+	if count != 1 {
+		t.Errorf("expected 1 fire, got %d", count)
 	}
 }
 
 func TestDebouncerIndependentPaths(t *testing.T) {
-	d := NewDebouncer(30 * time.Millisecond)
+	d, clock := newFakeDebouncer(30 * time.Millisecond)
 	defer d.Stop()
 
-	var mu sync.Mutex
-
 	fired := make(map[string]int)
 
 	for _, path := range []string{"a.go", "b.go", "c.go"} {
 		p := path
 
 		d.Trigger(p, func() {
-			mu.Lock()
 			fired[p]++
-			mu.Unlock()
 		})
 	}
 
-	time.Sleep(100 * time.Millisecond)
-
-	mu.Lock()
-	defer mu.Unlock()
+	clock.Advance(30 * time.Millisecond)
 
 	for _, path := range []string{"a.go", "b.go", "c.go"} {
 		if fired[path] != 1 {
@@ -59,36 +65,87 @@ func TestDebouncerIndependentPaths(t *testing.T) {
 }
 
 func TestDebouncerCustomDelay(t *testing.T) {
-	d := NewDebouncer(1 * time.Second)
+	d, clock := newFakeDebouncer(1 * time.Second)
 	defer d.Stop()
 
-	var fired atomic.Bool
+	fired := false
 
 	d.TriggerWithDelay("fast.go", 20*time.Millisecond, func() {
-		fired.Store(true)
+		fired = true
 	})
 
-	time.Sleep(80 * time.Millisecond)
+	clock.Advance(20 * time.Millisecond)
 
-	if !fired.Load() {
+	if !fired {
 		t.Error("expected custom delay to fire faster than default")
 	}
 }
 
 func TestDebouncerStop(t *testing.T) {
-	d := NewDebouncer(50 * time.Millisecond)
+	d, clock := newFakeDebouncer(50 * time.Millisecond)
 
-	var fired atomic.Bool
+	fired := false
 
 	d.Trigger("test.go", func() {
-		fired.Store(true)
+		fired = true
 	})
 
 	d.Stop()
 
-	time.Sleep(100 * time.Millisecond)
+	clock.Advance(time.Second)
 
-	if fired.Load() {
+	if fired {
 		t.Error("expected no fire after Stop()")
 	}
+
+	if clock.Pending() != 0 {
+		t.Errorf("expected Stop to cancel timers, %d pending", clock.Pending())
+	}
+}
+
+func TestDebouncerRealClock(t *testing.T) {
+	d := watcher.NewDebouncer(10 * time.Millisecond)
+	defer d.Stop()
+
+	fired := make(chan struct{})
+
+	var count atomic.Int32
+
+	d.Trigger("main.go", func() {
+		if count.Add(1) == 1 {
+			close(fired)
+		}
+	})
+
+	select {
+	case <-fired:
+	case <-time.After(5 * time.Second):
+		t.Fatal("debounced callback never fired")
+	}
+}
+
+func TestDebouncerCancel(t *testing.T) {
+	d, clock := newFakeDebouncer(50 * time.Millisecond)
+	defer d.Stop()
+
+	var fired []string
+
+	for _, key := range []string{"build:sub/a.go", "build:sub/b.go", "build:main.go"} {
+		d.Trigger(key, func() {
+			fired = append(fired, key)
+		})
+	}
+
+	n := d.Cancel(func(key string) bool {
+		return strings.HasPrefix(key, "build:sub/")
+	})
+	if n != 2 {
+		t.Errorf("Cancel = %d, want 2", n)
+	}
+
+	clock.Advance(time.Second)
+
+	if len(fired) != 1 || fired[0] != "build:main.go" {
+		t.Errorf("fired = %v, want only build:main.go", fired)
+	}
 }
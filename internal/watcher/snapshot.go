@@ -0,0 +1,90 @@
+package watcher
+
+import (
+	"io/fs"
+	"slices"
+	"strings"
+	"time"
+)
+
+// fileState is what a snapshot records about a file to detect changes.
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+func (f fileState) equal(g fileState) bool {
+	return f.size == g.size && f.modTime.Equal(g.modTime)
+}
+
+// snapshot maps every file below a root to its last known state. The
+// watcher keeps it current from events and diffs a fresh scan against it
+// when the kernel queue overflows and events were lost.
+type snapshot map[string]fileState
+
+// update records path's current state, or forgets it if it is gone or
+// is not a regular file.
+func (s snapshot) update(path string, info fs.FileInfo) {
+	if info == nil || !info.Mode().IsRegular() {
+		delete(s, path)
+
+		return
+	}
+
+	s[path] = fileState{size: info.Size(), modTime: info.ModTime()}
+}
+
+// remove forgets path and, if it was a directory, every file below it.
+// It returns the files forgotten from below path, sorted.
+func (s snapshot) remove(path string) []string {
+	if _, ok := s[path]; ok {
+		delete(s, path)
+
+		return nil
+	}
+
+	var removed []string
+
+	for p := range s {
+		if Within(p, path) {
+			removed = append(removed, p)
+			delete(s, p)
+		}
+	}
+
+	slices.Sort(removed)
+
+	return removed
+}
+
+// diff returns the events that turn s into next, sorted by path.
+func (s snapshot) diff(next snapshot) []Event {
+	var events []Event
+
+	for path, st := range next {
+		old, ok := s[path]
+
+		switch {
+		case !ok:
+			events = append(events, newEvent(path, Create))
+		case !old.equal(st):
+			events = append(events, newEvent(path, Modify))
+		}
+	}
+
+	for path := range s {
+		if _, ok := next[path]; !ok {
+			events = append(events, newEvent(path, Delete))
+		}
+	}
+
+	sortEvents(events)
+
+	return events
+}
+
+func sortEvents(events []Event) {
+	slices.SortFunc(events, func(a, b Event) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+}
@@ -0,0 +1,42 @@
+package watcher
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestSnapshotDiff(t *testing.T) {
+	t0 := time.Unix(1000, 0)
+	old := snapshot{
+		"a.go":     {size: 1, modTime: t0},
+		"b.go":     {size: 1, modTime: t0},
+		"c.go":     {size: 1, modTime: t0},
+		"sub/d.go": {size: 1, modTime: t0},
+	}
+	next := snapshot{
+		"a.go":     {size: 1, modTime: t0},
+		"b.go":     {size: 2, modTime: t0},
+		"c.go":     {size: 1, modTime: t0.Add(time.Second)},
+		"sub/e.go": {size: 1, modTime: t0},
+	}
+
+	var got []string
+	for _, ev := range old.diff(next) {
+		got = append(got, string(ev.Type)+" "+ev.Path)
+	}
+
+	want := []string{"modify b.go", "modify c.go", "delete sub/d.go", "create sub/e.go"}
+	if !slices.Equal(got, want) {
+		t.Errorf("diff = %v, want %v", got, want)
+	}
+}
+
+func TestSnapshotRemoveDir(t *testing.T) {
+	s := snapshot{"sub/a.go": {}, "sub/b/c.go": {}, "subway.go": {}}
+	s.remove("sub")
+
+	if len(s) != 1 {
+		t.Errorf("remove(sub) left %v, want only subway.go", s)
+	}
+}
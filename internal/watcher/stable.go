@@ -0,0 +1,124 @@
+package watcher
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// Poll interval bounds for WaitStable.
+const (
+	minStablePoll = 10 * time.Millisecond
+	maxStablePoll = time.Second
+)
+
+// WaitStable polls path on clock until its size and modification time
+// have not changed for stableFor, then calls done(true). A path that does
+// not exist counts as unchanged while it stays missing. If the file is
+// still changing after maxWait, done(false) is called instead. done runs
+// once, on the clock's goroutine. The returned func abandons the wait
+// without calling done.
+func WaitStable(clock Clock, path string, stableFor, maxWait time.Duration, done func(stable bool)) (cancel func()) {
+	start := clock.Now()
+
+	w := &stableWait{
+		clock:     clock,
+		path:      path,
+		interval:  min(max(stableFor/4, minStablePoll), maxStablePoll),
+		stableFor: stableFor,
+		stableAt:  start.Add(stableFor),
+		deadline:  start.Add(maxWait),
+		last:      statState(path),
+		done:      done,
+	}
+
+	w.schedule()
+
+	return w.cancel
+}
+
+// fileStat is the state WaitStable compares between polls.
+type fileStat struct {
+	exists bool
+	state  fileState
+}
+
+func (f fileStat) equal(g fileStat) bool {
+	return f.exists == g.exists && f.state.equal(g.state)
+}
+
+type stableWait struct {
+	clock     Clock
+	path      string
+	interval  time.Duration
+	stableFor time.Duration
+	stableAt  time.Time
+	deadline  time.Time
+	last      fileStat
+	done      func(bool)
+
+	mu       sync.Mutex
+	timer    Timer
+	canceled bool
+}
+
+func (w *stableWait) schedule() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.canceled {
+		return
+	}
+
+	w.timer = w.clock.AfterFunc(w.interval, w.poll)
+}
+
+func (w *stableWait) poll() {
+	now := w.clock.Now()
+
+	cur := statState(w.path)
+	if !cur.equal(w.last) {
+		w.last = cur
+		w.stableAt = now.Add(w.stableFor)
+	}
+
+	switch {
+	case !now.Before(w.stableAt):
+		w.finish(true)
+	case !now.Before(w.deadline):
+		w.finish(false)
+	default:
+		w.schedule()
+	}
+}
+
+func (w *stableWait) finish(stable bool) {
+	w.mu.Lock()
+	canceled := w.canceled
+	w.canceled = true
+	w.mu.Unlock()
+
+	if !canceled {
+		w.done(stable)
+	}
+}
+
+func (w *stableWait) cancel() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.canceled = true
+
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+}
+
+func statState(path string) fileStat {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileStat{}
+	}
+
+	return fileStat{exists: true, state: fileState{size: info.Size(), modTime: info.ModTime()}}
+}
@@ -0,0 +1,91 @@
+package watcher_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/watcher"
+	"github.com/devaloi/watchdog/watchdogtest"
+)
+
+func TestWaitStable(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ingest.bin")
+	grow(t, path, 1)
+
+	clock := watchdogtest.NewClock(watchdogtest.Epoch)
+
+	var result []bool
+
+	watcher.WaitStable(clock, path, time.Second, 10*time.Second, func(stable bool) {
+		result = append(result, stable)
+	})
+
+	clock.Advance(500 * time.Millisecond)
+	grow(t, path, 2)
+
+	// The poll at 750ms sees the write, so the file is stable at 1750ms.
+	clock.Advance(900 * time.Millisecond)
+
+	if len(result) != 0 {
+		t.Fatalf("done before the file settled: %v", result)
+	}
+
+	clock.Advance(350 * time.Millisecond)
+
+	if len(result) != 1 || !result[0] {
+		t.Errorf("result = %v, want [true]", result)
+	}
+
+	if clock.Pending() != 0 {
+		t.Errorf("%d timers left after done", clock.Pending())
+	}
+}
+
+func TestWaitStableTimeout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ingest.bin")
+	clock := watchdogtest.NewClock(watchdogtest.Epoch)
+
+	var result []bool
+
+	watcher.WaitStable(clock, path, time.Second, time.Second, func(stable bool) {
+		result = append(result, stable)
+	})
+
+	for i := range 6 {
+		grow(t, path, i+1)
+		clock.Advance(200 * time.Millisecond)
+	}
+
+	if len(result) != 1 || result[0] {
+		t.Errorf("result = %v, want [false]", result)
+	}
+}
+
+func TestWaitStableCancel(t *testing.T) {
+	clock := watchdogtest.NewClock(watchdogtest.Epoch)
+
+	called := false
+
+	cancel := watcher.WaitStable(clock, "missing", time.Second, time.Minute, func(bool) {
+		called = true
+	})
+	cancel()
+
+	clock.Advance(time.Minute)
+
+	if called || clock.Pending() != 0 {
+		t.Errorf("called = %v, pending = %d after cancel", called, clock.Pending())
+	}
+}
+
+// grow rewrites path with n bytes.
+func grow(t *testing.T, path string, n int) {
+	t.Helper()
+
+	err := os.WriteFile(path, make([]byte, n), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,164 @@
+package watcher
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// mode is how the watcher follows its root.
+type mode int
+
+const (
+	// modeDir watches the root directory recursively.
+	modeDir mode = iota
+	// modeFile watches the root file's parent and keeps only the root's
+	// events, so a save that replaces the file is still seen.
+	modeFile
+	// modePending watches the nearest existing ancestor of a root that
+	// does not exist yet, moving down as the missing directories appear.
+	modePending
+)
+
+// arm adds the watches for the root's current state.
+func (w *Watcher) arm() error {
+	info, err := w.stat(w.root)
+
+	switch {
+	case err == nil && info.IsDir():
+		w.mode = modeDir
+		w.anchor = w.root
+
+		return w.addRecursive(w.root)
+	case err == nil:
+		w.mode = modeFile
+		w.anchor = filepath.Dir(w.root)
+	case errors.Is(err, fs.ErrNotExist):
+		w.mode = modePending
+		w.anchor = existingAncestor(w.root)
+	default:
+		return err
+	}
+
+	_, addErr := w.add(w.anchor)
+
+	return addErr
+}
+
+// disarm removes every watch.
+func (w *Watcher) disarm() {
+	for _, d := range w.WatchedDirs() {
+		w.unwatch(d)
+	}
+}
+
+// route handles an event according to the watcher's mode and returns the
+// events to deliver for it.
+func (w *Watcher) route(ev Event) []Event {
+	gone := ev.Type == Delete || ev.Type == Rename
+
+	switch w.mode {
+	case modeDir:
+		events := w.track(ev)
+
+		// The root itself went away: wait for it to come back.
+		if ev.Path == w.root && gone {
+			events = append(events, w.rearm(ev)...)
+		}
+
+		return events
+	case modeFile:
+		if ev.Path == w.root {
+			return w.track(ev)
+		}
+
+		if ev.Path == w.anchor && gone {
+			var events []Event
+			if _, ok := w.snap[w.root]; ok {
+				delete(w.snap, w.root)
+				events = append(events, newEvent(w.root, Delete))
+			}
+
+			return append(events, w.rearm(ev)...)
+		}
+	case modePending:
+		created := ev.Type == Create && (ev.Path == w.root || Within(w.root, ev.Path))
+
+		if created || (ev.Path == w.anchor && gone) {
+			return w.rearm(ev)
+		}
+	}
+
+	return nil
+}
+
+// rearm replaces the watches after the root or one of its ancestors was
+// created or removed. If the root now exists it returns a create for it,
+// the triggering event itself when that created the root, followed for a
+// directory by creates for the files already inside it.
+func (w *Watcher) rearm(cause Event) []Event {
+	w.disarm()
+
+	err := w.arm()
+	if err != nil || w.mode == modePending {
+		return nil
+	}
+
+	ev := cause
+	if ev.Path != w.root || ev.Type != Create {
+		ev = newEvent(w.root, Create)
+	}
+
+	if w.mode == modeDir {
+		return append([]Event{ev}, w.adopt(w.root)...)
+	}
+
+	info, statErr := w.stat(w.root)
+	if statErr != nil {
+		return nil
+	}
+
+	w.snap.update(w.root, info)
+
+	return []Event{ev}
+}
+
+// rootSnapshot snapshots the files the root currently covers.
+func (w *Watcher) rootSnapshot() snapshot {
+	switch w.mode {
+	case modeDir:
+		return w.scan(w.root)
+	case modeFile:
+		s := make(snapshot)
+
+		info, err := w.stat(w.root)
+		if err == nil {
+			s.update(w.root, info)
+		}
+
+		return s
+	default:
+		return make(snapshot)
+	}
+}
+
+// existingAncestor returns the nearest ancestor of path that is an
+// existing directory.
+func existingAncestor(path string) string {
+	dir := filepath.Dir(path)
+
+	for {
+		info, err := os.Stat(dir)
+		if err == nil && info.IsDir() {
+			return dir
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir
+		}
+
+		dir = parent
+	}
+}
@@ -7,7 +7,10 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/fsnotify/fsnotify"
 )
@@ -21,8 +24,33 @@ const (
 	Modify EventType = "modify"
 	Delete EventType = "delete"
 	Rename EventType = "rename"
+	// Resync is emitted after the watcher recovers from a kernel queue
+	// overflow. Its Path is the watched root; the create, modify and delete
+	// events found by the rescan are emitted before it.
+	Resync EventType = "resync"
+	// Timeout is emitted when a rule's stable_for wait gives up on a file
+	// that kept changing; see WaitStable.
+	Timeout EventType = "timeout"
+	// Chmod reports a permission or other attribute change.
+	Chmod EventType = "chmod"
+	// CloseWrite reports that a writer closed the file, so its content is
+	// complete. It is only emitted on Linux, by a watcher created with
+	// CloseWriteEvents.
+	CloseWrite EventType = "close_write"
 )
 
+// OptIn reports whether rules receive t only when they list it in their
+// events: watchdog's own notices, and chmod and close_write, which would
+// otherwise accompany most ordinary changes.
+func (t EventType) OptIn() bool {
+	switch t {
+	case Resync, Timeout, Chmod, CloseWrite:
+		return true
+	default:
+		return false
+	}
+}
+
 // Event represents a single file system change.
 type Event struct {
 	Path string
@@ -31,95 +59,440 @@ type Event struct {
 	Dir  string
 }
 
-// Watcher recursively watches directories and emits Events.
+// Source produces file system events. Watcher is the fsnotify backend;
+// other backends (polling, journal replay, file lists) implement the same
+// interface so the engine and actions don't depend on fsnotify.
+type Source interface {
+	// Start begins producing events. It is called once.
+	Start() error
+	// Events delivers events until the source is closed.
+	Events() <-chan Event
+	// Errors delivers non-fatal errors until the source is closed.
+	Errors() <-chan error
+	// WatchedDirs returns the directories currently being watched.
+	WatchedDirs() []string
+	// Close stops the source and closes both channels.
+	Close() error
+}
+
+var errAlreadyStarted = errors.New("watcher: already started")
+
+// Watcher recursively watches directories with fsnotify and emits Events.
 type Watcher struct {
-	fsw    *fsnotify.Watcher
-	Events chan Event
-	Errors chan error
-	done   chan struct{}
-	wg     sync.WaitGroup
-	root   string
+	fsw       *fsnotify.Watcher
+	events    chan Event
+	errors    chan error
+	done      chan struct{}
+	wg        sync.WaitGroup
+	root      string
+	mode      mode
+	anchor    string // root, or the directory watched for it
+	follow    bool
+	closes    bool
+	cw        *closeWatcher
+	saves     *saves
+	snap      snapshot
+	dirsMu    sync.Mutex
+	dirs      map[string]string // watched path -> resolved path
+	owners    map[string]string // resolved path -> watched path
+	mu        sync.Mutex
+	started   bool
+	closed    bool
+	closeOnce sync.Once
 }
 
-// New creates a Watcher that recursively watches the given root directory.
-func New(root string) (*Watcher, error) {
-	fsw, err := fsnotify.NewWatcher()
+var _ Source = (*Watcher)(nil)
+
+// Option configures a Watcher.
+type Option func(*Watcher)
+
+// FollowSymlinks makes the watcher descend into symlinked directories.
+// Events below a link are reported under the link's path. A directory
+// reachable by several paths is watched once, under the first path
+// found, which also stops links that point back up the tree from looping.
+func FollowSymlinks() Option {
+	return func(w *Watcher) {
+		w.follow = true
+	}
+}
+
+// TempPatterns replaces DefaultTempPatterns as the editor temporary-file
+// patterns, matched against base names. Events for matching files are
+// dropped, and a file deleted or renamed away and recreated within a few
+// milliseconds, as editors do when saving, is reported as one Modify.
+// With no patterns, save sequences are reported as they happen.
+func TempPatterns(patterns ...string) Option {
+	return func(w *Watcher) {
+		w.saves = newSaves(patterns)
+	}
+}
+
+// CloseWriteEvents enables CloseWrite events, read from a second inotify
+// instance. Start fails on systems without inotify.
+func CloseWriteEvents() Option {
+	return func(w *Watcher) {
+		w.closes = true
+	}
+}
+
+// New creates and starts a Watcher that recursively watches root.
+func New(root string, opts ...Option) (*Watcher, error) {
+	w := NewFSNotify(root, opts...)
+
+	err := w.Start()
 	if err != nil {
 		return nil, err
 	}
 
+	return w, nil
+}
+
+// NewFSNotify creates a Watcher for root without starting it.
+func NewFSNotify(root string, opts ...Option) *Watcher {
 	w := &Watcher{
-		fsw:    fsw,
-		Events: make(chan Event, 128),
-		Errors: make(chan error, 16),
+		events: make(chan Event, 128),
+		errors: make(chan error, 16),
 		done:   make(chan struct{}),
-		root:   root,
+		root:   filepath.Clean(root),
+		saves:  newSaves(DefaultTempPatterns),
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	return w
+}
+
+// Start adds watches for root and begins delivering events. A directory
+// root is watched recursively. A file root is watched through its parent
+// directory, so saves that replace the file are seen. A root that does not
+// exist yet is watched through its nearest existing ancestor until it
+// appears, and is waited for again if it is later removed.
+func (w *Watcher) Start() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.started || w.closed {
+		return errAlreadyStarted
+	}
+
+	fsw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	if w.closes {
+		cw, cwErr := newCloseWatcher()
+		if cwErr != nil {
+			_ = fsw.Close()
+
+			return cwErr
+		}
+
+		w.cw = cw
 	}
 
-	addErr := w.addRecursive(root)
-	if addErr != nil {
+	w.fsw = fsw
+	w.dirs = make(map[string]string)
+	w.owners = make(map[string]string)
+
+	armErr := w.arm()
+	if armErr != nil {
 		_ = fsw.Close()
+		w.cw.close()
+		w.fsw = nil
 
-		return nil, addErr
+		return armErr
 	}
 
+	w.snap = w.rootSnapshot()
+	w.started = true
 	w.wg.Add(1)
 
 	go w.loop()
 
-	return w, nil
+	return nil
+}
+
+// Events returns the channel events are delivered on.
+func (w *Watcher) Events() <-chan Event {
+	return w.events
+}
+
+// Errors returns the channel watcher errors are delivered on.
+func (w *Watcher) Errors() <-chan error {
+	return w.errors
 }
 
 // Close stops the watcher and releases resources.
 func (w *Watcher) Close() error {
-	close(w.done)
-	err := w.fsw.Close()
-	w.wg.Wait()
+	w.mu.Lock()
+	started := w.started
+	w.closed = true
+	w.mu.Unlock()
+
+	var err error
+
+	w.closeOnce.Do(func() {
+		close(w.done)
+
+		if !started {
+			close(w.events)
+			close(w.errors)
+
+			return
+		}
+
+		err = w.fsw.Close()
+		w.cw.close()
+		w.wg.Wait()
+	})
 
 	return err
 }
 
-// WatchedDirs returns the list of directories currently being watched.
+// WatchedDirs returns the directories currently being watched, sorted.
 func (w *Watcher) WatchedDirs() []string {
-	list := w.fsw.WatchList()
+	w.dirsMu.Lock()
+	defer w.dirsMu.Unlock()
+
+	if w.dirs == nil {
+		return nil
+	}
+
+	dirs := make([]string, 0, len(w.dirs))
+	for d := range w.dirs {
+		dirs = append(dirs, d)
+	}
 
-	return list
+	slices.Sort(dirs)
+
+	return dirs
 }
 
 // ParseEventType converts a string to an EventType, returning an error for unknown values.
 func ParseEventType(s string) (EventType, error) {
 	switch EventType(s) {
-	case Create, Modify, Delete, Rename:
+	case Create, Modify, Delete, Rename, Resync, Timeout, Chmod, CloseWrite:
 		return EventType(s), nil
 	default:
 		return "", errors.New("unknown event type: " + s)
 	}
 }
 
-func (w *Watcher) addRecursive(root string) error {
-	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+// addRecursive watches dir and every directory below it.
+func (w *Watcher) addRecursive(dir string) error {
+	added, err := w.add(dir)
+	if err != nil || !added {
+		return err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+
+		if !e.IsDir() && !(w.follow && isDirLink(path, e)) {
+			continue
+		}
+
+		addErr := w.addRecursive(path)
+		if addErr != nil {
+			return addErr
+		}
+	}
+
+	return nil
+}
+
+// add watches a single directory. It reports false, without error, if
+// another path already watches the same directory: a duplicate link, or a
+// cycle back to an ancestor.
+func (w *Watcher) add(dir string) (bool, error) {
+	resolved := dir
+
+	if w.follow {
+		var err error
+
+		resolved, err = filepath.EvalSymlinks(dir)
 		if err != nil {
-			return err
+			return false, err
 		}
+	}
+
+	w.dirsMu.Lock()
+	owner, seen := w.owners[resolved]
+	w.dirsMu.Unlock()
+
+	if seen && owner != dir {
+		return false, nil
+	}
+
+	err := w.fsw.Add(dir)
+	if err != nil {
+		return false, err
+	}
+
+	err = w.cw.add(dir)
+	if err != nil {
+		_ = w.fsw.Remove(dir)
+
+		return false, err
+	}
+
+	w.dirsMu.Lock()
+	w.dirs[dir] = resolved
+	w.owners[resolved] = dir
+	w.dirsMu.Unlock()
+
+	return true, nil
+}
+
+// isDirLink reports whether a directory entry is a symlink to a directory.
+func isDirLink(path string, e fs.DirEntry) bool {
+	if e.Type()&fs.ModeSymlink == 0 {
+		return false
+	}
+
+	info, err := os.Stat(path)
+
+	return err == nil && info.IsDir()
+}
+
+// scan snapshots the files in dir and every directory below it. With
+// FollowSymlinks it descends only into directories watched under the path
+// it reached them by, so a link back up the tree is not walked again.
+func (w *Watcher) scan(dir string) snapshot {
+	s := make(snapshot)
+	w.scanDir(s, dir)
+
+	return s
+}
 
-		if d.IsDir() {
-			return w.fsw.Add(path)
+func (w *Watcher) scanDir(s snapshot, dir string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+
+		if e.IsDir() || (w.follow && isDirLink(path, e)) {
+			if w.owns(path) {
+				w.scanDir(s, path)
+			}
+
+			continue
 		}
 
-		return nil
-	})
+		info, infoErr := w.stat(path)
+		if infoErr == nil {
+			s.update(path, info)
+		}
+	}
+}
+
+// owns reports whether dir is watched under its own path rather than
+// through another path to the same directory. Without FollowSymlinks no
+// directory has a second path.
+func (w *Watcher) owns(dir string) bool {
+	if !w.follow {
+		return true
+	}
+
+	resolved, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+
+	w.dirsMu.Lock()
+	defer w.dirsMu.Unlock()
+
+	return w.owners[resolved] == dir
+}
+
+// stat returns a path's FileInfo, following a symlink only when the
+// watcher follows symlinks.
+func (w *Watcher) stat(path string) (fs.FileInfo, error) {
+	if w.follow {
+		return os.Stat(path)
+	}
+
+	return os.Lstat(path)
+}
+
+// unwatch removes the watches for dir and every directory below it,
+// reporting whether dir was watched.
+func (w *Watcher) unwatch(dir string) bool {
+	w.dirsMu.Lock()
+	defer w.dirsMu.Unlock()
+
+	_, watched := w.dirs[dir]
+
+	for d, resolved := range w.dirs {
+		if d == dir || Within(d, dir) {
+			// The kernel drops watches for deleted directories itself, but
+			// a directory moved out of the tree, or a removed link's
+			// target, would keep reporting.
+			_ = w.fsw.Remove(d)
+			w.cw.remove(d)
+			delete(w.dirs, d)
+			delete(w.owners, resolved)
+		}
+	}
+
+	return watched
+}
+
+// Within reports whether path is strictly below dir. Both are clean
+// paths, as in events; below "." means any relative path that stays
+// inside it.
+func Within(path, dir string) bool {
+	const sep = string(filepath.Separator)
+
+	switch {
+	case dir == ".":
+		return path != "." && path != ".." && !strings.HasPrefix(path, ".."+sep) && !filepath.IsAbs(path)
+	case strings.HasSuffix(dir, sep):
+		return path != dir && strings.HasPrefix(path, dir)
+	default:
+		return strings.HasPrefix(path, dir+sep)
+	}
 }
 
 func (w *Watcher) loop() {
 	defer w.wg.Done()
-	defer close(w.Events)
-	defer close(w.Errors)
+	defer close(w.events)
+	defer close(w.errors)
+
+	// flush fires when the earliest event held by saves is due.
+	timer := time.NewTimer(0)
+	timer.Stop()
 
 	for {
+		var flush <-chan time.Time
+
+		if due, ok := w.saves.next(); ok {
+			timer.Reset(time.Until(due))
+			flush = timer.C
+		}
+
 		select {
 		case <-w.done:
 			return
 
+		case now := <-flush:
+			for _, e := range w.saves.expire(now) {
+				if !w.send(e) {
+					return
+				}
+			}
+
 		case ev, ok := <-w.fsw.Events:
 			if !ok {
 				return
@@ -130,17 +503,17 @@ func (w *Watcher) loop() {
 				continue
 			}
 
-			if event.Type == Create {
-				info, statErr := os.Stat(ev.Name)
-				if statErr == nil && info.IsDir() {
-					_ = w.addRecursive(ev.Name)
+			for _, e := range w.route(*event) {
+				if !w.deliver(e) {
+					return
 				}
 			}
 
-			select {
-			case w.Events <- *event:
-			case <-w.done:
-				return
+		case path := <-w.cw.events():
+			for _, e := range w.route(newEvent(path, CloseWrite)) {
+				if !w.deliver(e) {
+					return
+				}
 			}
 
 		case err, ok := <-w.fsw.Errors:
@@ -149,14 +522,143 @@ func (w *Watcher) loop() {
 			}
 
 			select {
-			case w.Errors <- err:
+			case w.errors <- err:
 			case <-w.done:
 				return
 			}
+
+			if errors.Is(err, fsnotify.ErrEventOverflow) && !w.resync() {
+				return
+			}
 		}
 	}
 }
 
+// track keeps watches and the snapshot current for an event and returns
+// the events to deliver for it: none for a duplicate create, and for a new
+// directory the event itself followed by creates for the files already in
+// it, which appeared before its watch was added.
+func (w *Watcher) track(ev Event) []Event {
+	switch ev.Type {
+	case Create, Modify, CloseWrite:
+		info, err := w.stat(ev.Path)
+		if err != nil {
+			w.snap.remove(ev.Path)
+
+			return []Event{ev}
+		}
+
+		if info.IsDir() {
+			if ev.Type != Create {
+				return []Event{ev}
+			}
+
+			_ = w.addRecursive(ev.Path)
+
+			return append([]Event{ev}, w.adopt(ev.Path)...)
+		}
+
+		// A create for a file already in the snapshot was either synthesized
+		// by adopt or resync before fsnotify reported it, or replaced the file,
+		// as an atomic save does, which is a modification.
+		old, known := w.snap[ev.Path]
+		w.snap.update(ev.Path, info)
+
+		if known && ev.Type == Create {
+			if old.equal(w.snap[ev.Path]) {
+				return nil
+			}
+
+			ev.Type = Modify
+		}
+	case Delete, Rename:
+		removed := w.snap.remove(ev.Path)
+
+		// A directory removed or moved out as a whole reports only itself;
+		// report the files it held too.
+		if w.unwatch(ev.Path) {
+			events := []Event{ev}
+			for _, p := range removed {
+				events = append(events, newEvent(p, Delete))
+			}
+
+			return events
+		}
+	}
+
+	return []Event{ev}
+}
+
+// adopt adds the files below a new directory to the snapshot and returns
+// a Create event for each one not already known, sorted by path.
+func (w *Watcher) adopt(dir string) []Event {
+	var events []Event
+
+	for path, st := range w.scan(dir) {
+		if _, ok := w.snap[path]; ok {
+			continue
+		}
+
+		w.snap[path] = st
+		events = append(events, newEvent(path, Create))
+	}
+
+	sortEvents(events)
+
+	return events
+}
+
+// resync recovers from lost events: it rebuilds the watches, so
+// directories created or removed while the queue was full are accounted
+// for, emits the events that turn the snapshot into the current tree,
+// then emits a Resync event. It reports false if the watcher was closed
+// meanwhile.
+func (w *Watcher) resync() bool {
+	w.disarm()
+	_ = w.arm()
+
+	next := w.rootSnapshot()
+	events := w.snap.diff(next)
+	w.snap = next
+
+	for _, ev := range events {
+		if !w.deliver(ev) {
+			return false
+		}
+	}
+
+	// Release held deletes now so they all precede the Resync event.
+	for _, ev := range w.saves.expire(time.Now().Add(saveWindow)) {
+		if !w.send(ev) {
+			return false
+		}
+	}
+
+	return w.send(newEvent(w.root, Resync))
+}
+
+// deliver passes an event through the save filter and sends what it
+// releases, reporting false if the watcher was closed.
+func (w *Watcher) deliver(ev Event) bool {
+	for _, e := range w.saves.filter(ev, time.Now()) {
+		if !w.send(e) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// send delivers an event, reporting false if the watcher was closed.
+func (w *Watcher) send(ev Event) bool {
+	select {
+	case w.events <- ev:
+		return true
+	case <-w.done:
+		return false
+	}
+}
+
 func translate(ev fsnotify.Event) *Event {
 	var t EventType
 
@@ -169,16 +671,26 @@ func translate(ev fsnotify.Event) *Event {
 		t = Delete
 	case ev.Op.Has(fsnotify.Rename):
 		t = Rename
+	case ev.Op.Has(fsnotify.Chmod):
+		t = Chmod
 	default:
 		return nil
 	}
 
-	rel := ev.Name
+	event := newEvent(ev.Name, t)
+
+	return &event
+}
+
+// newEvent builds an Event for path. The path is cleaned, since fsnotify
+// reports "./a.go" under a watch on "." where a scan records "a.go".
+func newEvent(path string, t EventType) Event {
+	path = filepath.Clean(path)
 
-	return &Event{
-		Path: rel,
+	return Event{
+		Path: path,
 		Type: t,
-		Name: filepath.Base(rel),
-		Dir:  filepath.Dir(rel),
+		Name: filepath.Base(path),
+		Dir:  filepath.Dir(path),
 	}
 }
@@ -1,10 +1,16 @@
 package watcher
 
 import (
+	"errors"
+	"maps"
 	"os"
 	"path/filepath"
+	"runtime"
+	"slices"
 	"testing"
 	"time"
+
+	"github.com/fsnotify/fsnotify"
 )
 
 func TestWatcherCreateEvent(t *testing.T) {
@@ -161,6 +167,746 @@ func TestWatcherNewDirAutoWatch(t *testing.T) {
 	}
 }
 
+func TestWatcherNewDirContents(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = w.Close() }()
+
+	// Build a tree outside the watch and move it in, as cp -r or a
+	// checkout would, so its files never produce events of their own.
+	staged := filepath.Join(t.TempDir(), "pkg")
+
+	mkErr := os.MkdirAll(filepath.Join(staged, "inner"), 0o750)
+	if mkErr != nil {
+		t.Fatal(mkErr)
+	}
+
+	for _, name := range []string{"a.go", "inner/b.go"} {
+		writeErr := os.WriteFile(filepath.Join(staged, name), []byte("package x"), 0o600)
+		if writeErr != nil {
+			t.Fatal(writeErr)
+		}
+	}
+
+	renameErr := os.Rename(staged, filepath.Join(dir, "pkg"))
+	if renameErr != nil {
+		t.Fatal(renameErr)
+	}
+
+	var got []string
+	for range 3 {
+		ev := waitForEvent(t, w)
+		got = append(got, string(ev.Type)+" "+ev.Path)
+	}
+
+	want := []string{
+		"create " + filepath.Join(dir, "pkg"),
+		"create " + filepath.Join(dir, "pkg", "a.go"),
+		"create " + filepath.Join(dir, "pkg", "inner", "b.go"),
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestWatcherNewDirDedup(t *testing.T) {
+	dir := t.TempDir()
+
+	w, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = w.Close() }()
+
+	path := filepath.Join(dir, "a", "b", "c.go")
+
+	mkErr := os.MkdirAll(filepath.Dir(path), 0o750)
+	if mkErr != nil {
+		t.Fatal(mkErr)
+	}
+
+	writeErr := os.WriteFile(path, nil, 0o600)
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+
+	creates := 0
+	timeout := time.After(300 * time.Millisecond)
+
+	for done := false; !done; {
+		select {
+		case ev := <-w.Events():
+			if ev.Path == path && ev.Type == Create {
+				creates++
+			}
+		case <-timeout:
+			done = true
+		}
+	}
+
+	if creates != 1 {
+		t.Errorf("got %d create events for %s, want 1", creates, path)
+	}
+}
+
+func TestWatcherDirRemoved(t *testing.T) {
+	outside := t.TempDir()
+
+	tests := []struct {
+		name   string
+		remove func(sub string) error
+		want   EventType
+	}{
+		{"delete", os.RemoveAll, Delete},
+		{"move out", func(sub string) error {
+			return os.Rename(sub, filepath.Join(outside, "moved"))
+		}, Rename},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			sub := filepath.Join(dir, "sub")
+			file := filepath.Join(sub, "inner", "a.go")
+
+			mkErr := os.MkdirAll(filepath.Dir(file), 0o750)
+			if mkErr != nil {
+				t.Fatal(mkErr)
+			}
+
+			writeErr := os.WriteFile(file, nil, 0o600)
+			if writeErr != nil {
+				t.Fatal(writeErr)
+			}
+
+			w, err := New(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer func() { _ = w.Close() }()
+
+			removeErr := tt.remove(sub)
+			if removeErr != nil {
+				t.Fatal(removeErr)
+			}
+
+			gotSub, gotFile := false, 0
+
+			for !gotSub || gotFile == 0 {
+				ev := waitForEvent(t, w)
+
+				switch {
+				case ev.Path == sub && ev.Type == tt.want:
+					gotSub = true
+				case ev.Path == file && ev.Type == Delete:
+					gotFile++
+				}
+			}
+
+			if gotFile != 1 {
+				t.Errorf("got %d delete events for %s, want 1", gotFile, file)
+			}
+
+			if dirs := w.WatchedDirs(); !slices.Equal(dirs, []string{dir}) {
+				t.Errorf("WatchedDirs = %v, want only the root", dirs)
+			}
+		})
+	}
+}
+
+func TestWatcherRelativeDirRemoved(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	file := filepath.Join("sub", "a.go")
+
+	mkErr := os.Mkdir("sub", 0o750)
+	if mkErr != nil {
+		t.Fatal(mkErr)
+	}
+
+	writeErr := os.WriteFile(file, nil, 0o600)
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+
+	w, err := New(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = w.Close() }()
+
+	if dirs := w.WatchedDirs(); !slices.Equal(dirs, []string{".", "sub"}) {
+		t.Fatalf("WatchedDirs = %v, want [. sub]", dirs)
+	}
+
+	removeErr := os.RemoveAll("sub")
+	if removeErr != nil {
+		t.Fatal(removeErr)
+	}
+
+	// fsnotify reports "./sub"; the watch is stored as "sub".
+	ev := waitForPath(t, w, "sub")
+	if ev.Type != Delete {
+		t.Errorf("expected delete for sub, got %+v", ev)
+	}
+
+	if dirs := w.WatchedDirs(); !slices.Equal(dirs, []string{"."}) {
+		t.Errorf("WatchedDirs = %v, want only the root", dirs)
+	}
+}
+
+func TestWatcherDirMovedWithin(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	dst := filepath.Join(dir, "dst")
+
+	mkErr := os.Mkdir(src, 0o750)
+	if mkErr != nil {
+		t.Fatal(mkErr)
+	}
+
+	writeErr := os.WriteFile(filepath.Join(src, "a.go"), nil, 0o600)
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+
+	w, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = w.Close() }()
+
+	renameErr := os.Rename(src, dst)
+	if renameErr != nil {
+		t.Fatal(renameErr)
+	}
+
+	moved := filepath.Join(dst, "a.go")
+
+	waitForPath(t, w, moved)
+
+	if dirs := w.WatchedDirs(); !slices.Equal(dirs, []string{dir, dst}) {
+		t.Errorf("WatchedDirs = %v, want [%s %s]", dirs, dir, dst)
+	}
+
+	appendErr := os.WriteFile(moved, []byte("x"), 0o600)
+	if appendErr != nil {
+		t.Fatal(appendErr)
+	}
+
+	ev := waitForEvent(t, w)
+	if ev.Path != moved || ev.Type != Modify {
+		t.Errorf("expected modify for %s at its new location, got %+v", moved, ev)
+	}
+}
+
+func TestWatcherFollowSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	target := t.TempDir()
+	link := filepath.Join(dir, "vendor")
+
+	linkErr := os.Symlink(target, link)
+	if linkErr != nil {
+		t.Fatal(linkErr)
+	}
+
+	// A link back to the root must not be followed forever.
+	loopErr := os.Symlink(dir, filepath.Join(target, "loop"))
+	if loopErr != nil {
+		t.Fatal(loopErr)
+	}
+
+	preErr := os.WriteFile(filepath.Join(target, "pre.go"), nil, 0o600)
+	if preErr != nil {
+		t.Fatal(preErr)
+	}
+
+	plain, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dirs := plain.WatchedDirs(); !slices.Equal(dirs, []string{dir}) {
+		t.Errorf("without FollowSymlinks, WatchedDirs = %v", dirs)
+	}
+
+	_ = plain.Close()
+
+	w, err := New(dir, FollowSymlinks())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = w.Close() }()
+
+	if dirs := w.WatchedDirs(); !slices.Equal(dirs, []string{dir, link}) {
+		t.Errorf("WatchedDirs = %v, want [%s %s]", dirs, dir, link)
+	}
+
+	// The snapshot walks the link once and skips the loop back to dir.
+	if got, want := slices.Sorted(maps.Keys(w.snap)), []string{filepath.Join(link, "pre.go")}; !slices.Equal(got, want) {
+		t.Errorf("snapshot = %v, want %v", got, want)
+	}
+
+	writeErr := os.WriteFile(filepath.Join(target, "lib.go"), nil, 0o600)
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+
+	ev := waitForEvent(t, w)
+	if want := filepath.Join(link, "lib.go"); ev.Path != want || ev.Type != Create {
+		t.Errorf("expected create for %s, got %+v", want, ev)
+	}
+}
+
+func TestWatcherRelativeRoot(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	mkErr := os.MkdirAll(filepath.Join("sub", "deep"), 0o750)
+	if mkErr != nil {
+		t.Fatal(mkErr)
+	}
+
+	for _, path := range []string{"top.go", filepath.Join("sub", "deep", "a.go")} {
+		writeErr := os.WriteFile(path, nil, 0o600)
+		if writeErr != nil {
+			t.Fatal(writeErr)
+		}
+	}
+
+	w, err := New(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = w.Close() }()
+
+	if got, want := slices.Sorted(maps.Keys(w.snap)), []string{filepath.Join("sub", "deep", "a.go"), "top.go"}; !slices.Equal(got, want) {
+		t.Errorf("snapshot = %v, want %v", got, want)
+	}
+}
+
+func TestWatcherFileTarget(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "config.toml")
+
+	writeErr := os.WriteFile(file, []byte("a = 1"), 0o600)
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+
+	w, err := New(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = w.Close() }()
+
+	if dirs := w.WatchedDirs(); !slices.Equal(dirs, []string{dir}) {
+		t.Errorf("WatchedDirs = %v, want the parent", dirs)
+	}
+
+	siblingErr := os.WriteFile(filepath.Join(dir, "other.toml"), nil, 0o600)
+	if siblingErr != nil {
+		t.Fatal(siblingErr)
+	}
+
+	// An atomic save writes a temporary file and renames it over the target.
+	tmp := filepath.Join(dir, ".config.toml.tmp")
+
+	tmpErr := os.WriteFile(tmp, []byte("a = 22"), 0o600)
+	if tmpErr != nil {
+		t.Fatal(tmpErr)
+	}
+
+	renameErr := os.Rename(tmp, file)
+	if renameErr != nil {
+		t.Fatal(renameErr)
+	}
+
+	ev := waitForEvent(t, w)
+	if ev.Path != file || ev.Type != Modify {
+		t.Errorf("expected modify for %s, got %+v", file, ev)
+	}
+}
+
+func TestWatcherPendingFile(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "build", "out", "output.log")
+
+	w, err := New(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = w.Close() }()
+
+	if dirs := w.WatchedDirs(); !slices.Equal(dirs, []string{dir}) {
+		t.Errorf("WatchedDirs = %v, want the nearest existing ancestor", dirs)
+	}
+
+	mkErr := os.MkdirAll(filepath.Dir(file), 0o750)
+	if mkErr != nil {
+		t.Fatal(mkErr)
+	}
+
+	writeErr := os.WriteFile(file, nil, 0o600)
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+
+	ev := waitForEvent(t, w)
+	if ev.Path != file || ev.Type != Create {
+		t.Errorf("expected create for %s, got %+v", file, ev)
+	}
+
+	appendErr := os.WriteFile(file, []byte("done"), 0o600)
+	if appendErr != nil {
+		t.Fatal(appendErr)
+	}
+
+	ev = waitForPath(t, w, file)
+	if ev.Type != Modify {
+		t.Errorf("expected modify once watching, got %+v", ev)
+	}
+}
+
+func TestWatcherPendingDir(t *testing.T) {
+	dir := t.TempDir()
+	site := filepath.Join(dir, "site")
+
+	w, err := New(site)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = w.Close() }()
+
+	staged := filepath.Join(t.TempDir(), "site")
+
+	mkErr := os.Mkdir(staged, 0o750)
+	if mkErr != nil {
+		t.Fatal(mkErr)
+	}
+
+	writeErr := os.WriteFile(filepath.Join(staged, "index.html"), nil, 0o600)
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+
+	renameErr := os.Rename(staged, site)
+	if renameErr != nil {
+		t.Fatal(renameErr)
+	}
+
+	page := filepath.Join(site, "index.html")
+
+	for _, want := range []string{site, page} {
+		ev := waitForEvent(t, w)
+		if ev.Path != want || ev.Type != Create {
+			t.Errorf("expected create for %s, got %+v", want, ev)
+		}
+	}
+
+	removeErr := os.RemoveAll(site)
+	if removeErr != nil {
+		t.Fatal(removeErr)
+	}
+
+	waitForPath(t, w, site)
+
+	if dirs := w.WatchedDirs(); !slices.Equal(dirs, []string{dir}) {
+		t.Errorf("after removal, WatchedDirs = %v, want the parent", dirs)
+	}
+}
+
+func TestWatcherAtomicSave(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "App.kt")
+
+	writeErr := os.WriteFile(file, []byte("v1"), 0o600)
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+
+	w, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = w.Close() }()
+
+	// JetBrains safe write: new content goes to a temporary file, the
+	// original is moved aside, the temporary file takes its place and the
+	// old copy is deleted.
+	tmp, old := file+"___jb_tmp___", file+"___jb_old___"
+
+	steps := []func() error{
+		func() error { return os.WriteFile(tmp, []byte("v2"), 0o600) },
+		func() error { return os.Rename(file, old) },
+		func() error { return os.Rename(tmp, file) },
+		func() error { return os.Remove(old) },
+	}
+
+	for _, step := range steps {
+		stepErr := step()
+		if stepErr != nil {
+			t.Fatal(stepErr)
+		}
+	}
+
+	ev := waitForEvent(t, w)
+	if ev.Path != file || ev.Type != Modify {
+		t.Errorf("expected one modify for %s, got %+v", file, ev)
+	}
+
+	select {
+	case ev := <-w.Events():
+		t.Errorf("unexpected extra event %+v", ev)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func TestWatcherChmodEvent(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "deploy.sh")
+
+	writeErr := os.WriteFile(path, []byte("#!/bin/sh"), 0o600)
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+
+	w, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = w.Close() }()
+
+	chmodErr := os.Chmod(path, 0o700)
+	if chmodErr != nil {
+		t.Fatal(chmodErr)
+	}
+
+	ev := waitForEvent(t, w)
+	if ev.Path != path || ev.Type != Chmod {
+		t.Errorf("expected chmod for %s, got %+v", path, ev)
+	}
+}
+
+func TestWatcherCloseWriteEvent(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("close_write needs inotify")
+	}
+
+	dir := t.TempDir()
+
+	w, err := New(dir, CloseWriteEvents())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = w.Close() }()
+
+	path := filepath.Join(dir, "upload.bin")
+
+	writeErr := os.WriteFile(path, []byte("data"), 0o600)
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+
+	// Create and Modify arrive first, from fsnotify.
+	for ev := waitForPath(t, w, path); ev.Type != CloseWrite; {
+		ev = waitForPath(t, w, path)
+	}
+}
+
+func TestWatcherStartLifecycle(t *testing.T) {
+	w := NewFSNotify(t.TempDir())
+
+	if dirs := w.WatchedDirs(); dirs != nil {
+		t.Errorf("WatchedDirs before Start = %v, want nil", dirs)
+	}
+
+	err := w.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w.Start() == nil {
+		t.Error("expected error starting twice")
+	}
+
+	if len(w.WatchedDirs()) != 1 {
+		t.Errorf("WatchedDirs = %v, want the root", w.WatchedDirs())
+	}
+
+	_ = w.Close()
+
+	if _, ok := <-w.Events(); ok {
+		t.Error("Events should be closed after Close")
+	}
+}
+
+func TestWatcherCloseBeforeStart(t *testing.T) {
+	w := NewFSNotify(t.TempDir())
+
+	_ = w.Close()
+
+	if _, ok := <-w.Events(); ok {
+		t.Error("Events should be closed")
+	}
+
+	if w.Start() == nil {
+		t.Error("expected error starting a closed watcher")
+	}
+}
+
+func TestWatcherOverflowResync(t *testing.T) {
+	dir := t.TempDir()
+	sub := filepath.Join(dir, "sub")
+
+	mkErr := os.Mkdir(sub, 0o750)
+	if mkErr != nil {
+		t.Fatal(mkErr)
+	}
+
+	w, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = w.Close() }()
+
+	// Drop the watch so the write below is lost, as it would be in an overflow.
+	rmErr := w.fsw.Remove(sub)
+	if rmErr != nil {
+		t.Fatal(rmErr)
+	}
+
+	lost := filepath.Join(sub, "lost.go")
+
+	writeErr := os.WriteFile(lost, []byte("package sub"), 0o600)
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+
+	w.fsw.Errors <- fsnotify.ErrEventOverflow
+
+	select {
+	case err := <-w.Errors():
+		if !errors.Is(err, fsnotify.ErrEventOverflow) {
+			t.Errorf("expected overflow error, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("overflow error was not forwarded")
+	}
+
+	ev := waitForEvent(t, w)
+	if ev.Type != Create || ev.Path != lost {
+		t.Errorf("expected synthesized create for %s, got %+v", lost, ev)
+	}
+
+	ev = waitForEvent(t, w)
+	if ev.Type != Resync || ev.Path != dir {
+		t.Errorf("expected resync for %s, got %+v", dir, ev)
+	}
+
+	if !slices.Contains(w.WatchedDirs(), sub) {
+		t.Error("resync should re-add the dropped watch")
+	}
+}
+
+func TestWatcherRelativeRootResync(t *testing.T) {
+	t.Chdir(t.TempDir())
+
+	deep := filepath.Join("sub", "deep")
+
+	mkErr := os.MkdirAll(deep, 0o750)
+	if mkErr != nil {
+		t.Fatal(mkErr)
+	}
+
+	paths := []string{"top.go", filepath.Join(deep, "a.go")}
+
+	for _, path := range paths {
+		writeErr := os.WriteFile(path, nil, 0o600)
+		if writeErr != nil {
+			t.Fatal(writeErr)
+		}
+	}
+
+	w, err := New(".")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() { _ = w.Close() }()
+
+	// fsnotify names files in "." as "./top.go"; events use clean paths,
+	// as the snapshot does.
+	for _, path := range paths {
+		writeErr := os.WriteFile(path, []byte("package x"), 0o600)
+		if writeErr != nil {
+			t.Fatal(writeErr)
+		}
+
+		ev := waitForPath(t, w, path)
+		if ev.Type != Modify {
+			t.Errorf("expected modify for %s, got %+v", path, ev)
+		}
+	}
+
+	w.fsw.Errors <- fsnotify.ErrEventOverflow
+
+	<-w.Errors()
+
+	// Both files still exist, so the rescan must not report them deleted.
+	for {
+		ev := waitForEvent(t, w)
+		if ev.Type == Resync {
+			break
+		}
+
+		if ev.Type == Delete {
+			t.Errorf("resync reported a delete for %s", ev.Path)
+		}
+	}
+}
+
+func TestWithin(t *testing.T) {
+	tests := []struct {
+		path, dir string
+		want      bool
+	}{
+		{"a/b", "a", true},
+		{"a", "a", false},
+		{"ab/c", "a", false},
+		{"sub/a.go", ".", true},
+		{"top.go", ".", true},
+		{".", ".", false},
+		{"../x", ".", false},
+		{"/x", ".", false},
+		{"/x", "/", true},
+		{"/", "/", false},
+	}
+
+	for _, tt := range tests {
+		if got := Within(tt.path, tt.dir); got != tt.want {
+			t.Errorf("Within(%q, %q) = %v, want %v", tt.path, tt.dir, got, tt.want)
+		}
+	}
+}
+
 func TestParseEventType(t *testing.T) {
 	tests := []struct {
 		input string
@@ -171,6 +917,9 @@ func TestParseEventType(t *testing.T) {
 		{"modify", Modify, false},
 		{"delete", Delete, false},
 		{"rename", Rename, false},
+		{"resync", Resync, false},
+		{"chmod", Chmod, false},
+		{"close_write", CloseWrite, false},
 		{"invalid", "", true},
 	}
 
@@ -190,9 +939,9 @@ func waitForEvent(t *testing.T, w *Watcher) Event {
 	t.Helper()
 
 	select {
-	case ev := <-w.Events:
+	case ev := <-w.Events():
 		return ev
-	case err := <-w.Errors:
+	case err := <-w.Errors():
 		t.Fatalf("watcher error: %v", err)
 	case <-time.After(2 * time.Second):
 		t.Fatal("timed out waiting for event")
@@ -200,3 +949,15 @@ func waitForEvent(t *testing.T, w *Watcher) Event {
 
 	return Event{}
 }
+
+// waitForPath discards events until one for path arrives.
+func waitForPath(t *testing.T, w *Watcher, path string) Event {
+	t.Helper()
+
+	for {
+		ev := waitForEvent(t, w)
+		if ev.Path == path {
+			return ev
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package watchdogtest
+
+import (
+	"sync"
+
+	"github.com/devaloi/watchdog/internal/action"
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+// RecordingAction is an action.Action that records every event it is
+// executed with instead of doing anything.
+type RecordingAction struct {
+	// Err is returned from every Execute call.
+	Err error
+
+	mu     sync.Mutex
+	events []watcher.Event
+}
+
+var _ action.Action = (*RecordingAction)(nil)
+
+// Execute records ev.
+func (r *RecordingAction) Execute(ev watcher.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, ev)
+
+	return r.Err
+}
+
+// Events returns the recorded events in execution order.
+func (r *RecordingAction) Events() []watcher.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]watcher.Event(nil), r.events...)
+}
+
+// Paths returns the paths of the recorded events in execution order.
+func (r *RecordingAction) Paths() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	paths := make([]string, 0, len(r.events))
+	for _, ev := range r.events {
+		paths = append(paths, ev.Path)
+	}
+
+	return paths
+}
+
+// Count returns the number of recorded executions.
+func (r *RecordingAction) Count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.events)
+}
+
+// Reset discards the recorded events.
+func (r *RecordingAction) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = nil
+}
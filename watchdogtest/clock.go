@@ -0,0 +1,21 @@
+// Package watchdogtest provides deterministic test doubles for watchdog: a
+// fake clock for Debouncer, an in-memory event source and recording
+// actions, plus a Harness wiring them to a config so rules can be tested
+// without real file system events or sleeps. Projects import it to test
+// their own watchdog.yaml.
+package watchdogtest
+
+import (
+	"time"
+
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+// Clock is a fake watcher.Clock. Time only moves when Advance is called,
+// and due timers run synchronously on the caller's goroutine.
+type Clock = watcher.ManualClock
+
+// NewClock creates a Clock set to start.
+func NewClock(start time.Time) *Clock {
+	return watcher.NewManualClock(start)
+}
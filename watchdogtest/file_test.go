@@ -0,0 +1,40 @@
+package watchdogtest_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/devaloi/watchdog/watchdogtest"
+)
+
+// TestNewFromFile uses only the exported API, as a project testing its
+// own watchdog.yaml would.
+func TestNewFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "watchdog.yaml")
+
+	err := os.WriteFile(path, []byte(`
+global:
+  debounce: 300ms
+rules:
+  - name: test
+    watch: ["**/*_test.go"]
+    action:
+      type: command
+      command: "go test ./..."
+`), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := watchdogtest.NewFromFile(t, path)
+
+	h.Emit("pkg/a_test.go", watchdogtest.Modify)
+	h.Emit("pkg/a.go", watchdogtest.Modify)
+	h.Advance(300 * time.Millisecond)
+
+	if got := h.Action("test").Paths(); len(got) != 1 || got[0] != "pkg/a_test.go" {
+		t.Errorf("test ran for %v", got)
+	}
+}
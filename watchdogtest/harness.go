@@ -0,0 +1,134 @@
+package watchdogtest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/config"
+	"github.com/devaloi/watchdog/internal/dispatch"
+	"github.com/devaloi/watchdog/internal/rule"
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+// Epoch is the fake clock's start time in a Harness.
+var Epoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Harness runs a config's rules against a fake Source and Clock, recording
+// each rule's action instead of executing it. Events go through the same
+// dispatcher as in watchdog itself, so debounce, stable_for and the
+// handling of removed directories behave as they do at run time. Events
+// are processed synchronously, so a test needs no sleeps:
+//
+//	h := watchdogtest.NewFromFile(t, "watchdog.yaml")
+//	h.Emit("main.go", watchdogtest.Modify)
+//	h.Advance(500 * time.Millisecond)
+//	if h.Action("build").Count() != 1 { ... }
+type Harness struct {
+	Source *Source
+	Clock  *Clock
+
+	dispatcher *dispatch.Dispatcher
+	actions    map[string]*RecordingAction
+}
+
+// New creates a Harness for cfg. Pending triggers are dropped when the
+// test ends.
+func New(t testing.TB, cfg *config.Config) *Harness {
+	t.Helper()
+
+	clock := NewClock(Epoch)
+
+	h := &Harness{
+		Source:  NewSource("."),
+		Clock:   clock,
+		actions: make(map[string]*RecordingAction, len(cfg.Rules)),
+	}
+
+	for _, r := range cfg.Rules {
+		h.actions[r.Name] = &RecordingAction{}
+	}
+
+	h.dispatcher = dispatch.New(cfg, clock, func(m rule.Match, ev watcher.Event) {
+		_ = h.actions[m.RuleName].Execute(ev)
+	})
+
+	_ = h.Source.Start()
+
+	t.Cleanup(func() {
+		h.dispatcher.Stop()
+
+		_ = h.Source.Close()
+	})
+
+	return h
+}
+
+// NewFromYAML parses and validates a config and creates a Harness for it.
+func NewFromYAML(t testing.TB, yaml string) *Harness {
+	t.Helper()
+
+	cfg, err := config.Parse([]byte(yaml))
+	if err != nil {
+		t.Fatalf("watchdogtest: invalid config: %v", err)
+	}
+
+	return New(t, cfg)
+}
+
+// NewFromFile loads and validates a config file, such as a project's
+// watchdog.yaml, and creates a Harness for it.
+func NewFromFile(t testing.TB, path string) *Harness {
+	t.Helper()
+
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("watchdogtest: invalid config %s: %v", path, err)
+	}
+
+	return New(t, cfg)
+}
+
+// Emit sends an event through the Source and processes it.
+func (h *Harness) Emit(path string, typ EventType) {
+	h.Source.Emit(path, typ)
+	h.Drain()
+}
+
+// Drain processes every event queued on the Source: each is matched
+// against the rules and debounced per rule and path, as watchdog does.
+func (h *Harness) Drain() {
+	for {
+		select {
+		case ev, ok := <-h.Source.Events():
+			if !ok {
+				return
+			}
+
+			h.dispatcher.Dispatch(ev)
+		default:
+			return
+		}
+	}
+}
+
+// Advance moves the fake clock forward, firing debounced actions that
+// become due.
+func (h *Harness) Advance(d time.Duration) {
+	h.Clock.Advance(d)
+}
+
+// Action returns the recording action for the named rule, or nil if the
+// config has no such rule.
+func (h *Harness) Action(ruleName string) *RecordingAction {
+	return h.actions[ruleName]
+}
+
+// Matches returns the rules that match an event without triggering them.
+func (h *Harness) Matches(path string, typ EventType) []string {
+	var names []string
+	for _, m := range h.dispatcher.Engine().Evaluate(Event(path, typ)) {
+		names = append(names, m.RuleName)
+	}
+
+	return names
+}
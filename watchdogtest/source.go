@@ -0,0 +1,121 @@
+package watchdogtest
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+// EventType is watcher.EventType, so tests outside this module can name
+// event types.
+type EventType = watcher.EventType
+
+// Event types, as reported by watchdog.
+const (
+	Create     = watcher.Create
+	Modify     = watcher.Modify
+	Delete     = watcher.Delete
+	Rename     = watcher.Rename
+	Chmod      = watcher.Chmod
+	CloseWrite = watcher.CloseWrite
+	Resync     = watcher.Resync
+	Timeout    = watcher.Timeout
+)
+
+// sourceBuffer matches the buffering of watcher.Watcher's channels.
+const sourceBuffer = 128
+
+// Source is an in-memory watcher.Source: events and errors queued with
+// Emit and EmitError arrive on buffered channels that are closed by Close.
+type Source struct {
+	events chan watcher.Event
+	errors chan error
+
+	mu      sync.Mutex
+	dirs    []string
+	started bool
+	closed  bool
+}
+
+var _ watcher.Source = (*Source)(nil)
+
+// NewSource creates a Source reporting dirs as watched.
+func NewSource(dirs ...string) *Source {
+	return &Source{
+		events: make(chan watcher.Event, sourceBuffer),
+		errors: make(chan error, sourceBuffer),
+		dirs:   dirs,
+	}
+}
+
+// Start records that the source was started; events can be queued before.
+func (s *Source) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.started = true
+
+	return nil
+}
+
+// Started reports whether Start was called.
+func (s *Source) Started() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.started
+}
+
+// Events returns the event channel.
+func (s *Source) Events() <-chan watcher.Event {
+	return s.events
+}
+
+// Errors returns the error channel.
+func (s *Source) Errors() <-chan error {
+	return s.errors
+}
+
+// Emit queues an event for path, filling in Name and Dir the way the
+// fsnotify watcher does.
+func (s *Source) Emit(path string, typ EventType) {
+	s.events <- Event(path, typ)
+}
+
+// EmitError queues a watcher error.
+func (s *Source) EmitError(err error) {
+	s.errors <- err
+}
+
+// WatchedDirs returns the directories passed to NewSource.
+func (s *Source) WatchedDirs() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.dirs...)
+}
+
+// Close closes the channels. It is safe to call more than once.
+func (s *Source) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+		close(s.errors)
+	}
+
+	return nil
+}
+
+// Event builds a watcher.Event for path with Name and Dir derived from it.
+func Event(path string, typ EventType) watcher.Event {
+	return watcher.Event{
+		Path: path,
+		Type: typ,
+		Name: filepath.Base(path),
+		Dir:  filepath.Dir(path),
+	}
+}
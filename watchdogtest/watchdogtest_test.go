@@ -0,0 +1,238 @@
+package watchdogtest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/devaloi/watchdog/internal/watcher"
+)
+
+const testYAML = `
+global:
+  debounce: 500ms
+  ignore: [.git]
+rules:
+  - name: build
+    watch: ["**/*.go"]
+    events: [create, modify]
+    action:
+      type: command
+      command: "go build ./..."
+  - name: css
+    watch: ["assets/**/*.css"]
+    debounce: 2s
+    action:
+      type: log
+      format: "{{.Path}}"
+`
+
+func TestClockAdvance(t *testing.T) {
+	c := NewClock(Epoch)
+
+	var fired []string
+
+	c.AfterFunc(2*time.Second, func() { fired = append(fired, "b") })
+	c.AfterFunc(time.Second, func() {
+		fired = append(fired, "a")
+		c.AfterFunc(500*time.Millisecond, func() { fired = append(fired, "a2") })
+	})
+	stopped := c.AfterFunc(time.Second, func() { fired = append(fired, "stopped") })
+
+	if !stopped.Stop() {
+		t.Error("Stop on a pending timer should report true")
+	}
+
+	c.Advance(999 * time.Millisecond)
+
+	if len(fired) != 0 {
+		t.Fatalf("fired early: %v", fired)
+	}
+
+	c.Advance(time.Second)
+
+	if want := []string{"a", "a2"}; !slices.Equal(fired, want) {
+		t.Errorf("fired = %v, want %v", fired, want)
+	}
+
+	if got := c.Now(); !got.Equal(Epoch.Add(1999 * time.Millisecond)) {
+		t.Errorf("Now = %v", got)
+	}
+
+	c.Advance(time.Millisecond)
+
+	if c.Pending() != 0 || len(fired) != 3 {
+		t.Errorf("pending = %d, fired = %v", c.Pending(), fired)
+	}
+}
+
+func TestHarnessDebounce(t *testing.T) {
+	h := NewFromYAML(t, testYAML)
+
+	for range 5 {
+		h.Emit("main.go", watcher.Modify)
+		h.Advance(100 * time.Millisecond)
+	}
+
+	if n := h.Action("build").Count(); n != 0 {
+		t.Fatalf("fired %d times before the debounce elapsed", n)
+	}
+
+	h.Advance(400 * time.Millisecond)
+
+	if got := h.Action("build").Paths(); !slices.Equal(got, []string{"main.go"}) {
+		t.Errorf("build fired for %v, want [main.go]", got)
+	}
+
+	h.Emit("main.go", watcher.Delete)
+	h.Emit(".git/x.go", watcher.Modify)
+	h.Advance(time.Minute)
+
+	if n := h.Action("build").Count(); n != 1 {
+		t.Errorf("build fired %d times, want 1", n)
+	}
+}
+
+func TestHarnessPerRuleDebounce(t *testing.T) {
+	h := NewFromYAML(t, testYAML)
+
+	h.Emit("assets/site.css", watcher.Modify)
+	h.Emit("cmd/app.go", watcher.Create)
+	h.Advance(time.Second)
+
+	if h.Action("css").Count() != 0 || h.Action("build").Count() != 1 {
+		t.Fatalf("after 1s: css=%d build=%d", h.Action("css").Count(), h.Action("build").Count())
+	}
+
+	h.Advance(time.Second)
+
+	ev := h.Action("css").Events()
+	if len(ev) != 1 || ev[0].Name != "site.css" || ev[0].Dir != "assets" {
+		t.Errorf("css events = %+v", ev)
+	}
+}
+
+func TestHarnessDirDeleteCancels(t *testing.T) {
+	h := NewFromYAML(t, testYAML)
+
+	h.Emit("pkg/a.go", watcher.Modify)
+	h.Emit("main.go", watcher.Modify)
+	h.Emit("pkg", watcher.Delete)
+	h.Advance(time.Second)
+
+	if got := h.Action("build").Paths(); !slices.Equal(got, []string{"main.go"}) {
+		t.Errorf("build fired for %v, want [main.go]", got)
+	}
+}
+
+func TestHarnessStableFor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "upload.csv")
+
+	h := NewFromYAML(t, `
+global:
+  debounce: 100ms
+rules:
+  - name: ingest
+    watch: ["**/*.csv"]
+    stable_for: 1s
+    stable_timeout: 2s
+    action:
+      type: log
+      format: "{{.Path}}"
+  - name: alert
+    watch: ["**/*.csv"]
+    events: [timeout]
+    action:
+      type: log
+      format: "{{.Path}}"
+`)
+
+	h.Emit("done.csv", watcher.Create)
+	h.Advance(time.Second)
+
+	if h.Action("ingest").Count() != 0 {
+		t.Fatal("fired before the file was stable")
+	}
+
+	h.Advance(200 * time.Millisecond)
+
+	if got := h.Action("ingest").Paths(); !slices.Equal(got, []string{"done.csv"}) {
+		t.Fatalf("ingest fired for %v, want [done.csv]", got)
+	}
+
+	h.Action("ingest").Reset()
+	h.Emit(path, watcher.Create)
+
+	// Keep writing past the 2s stable_timeout.
+	for i := range 15 {
+		err := os.WriteFile(path, make([]byte, i+1), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		h.Advance(200 * time.Millisecond)
+	}
+
+	if h.Action("ingest").Count() != 0 {
+		t.Error("ingest should not fire for a file that never settled")
+	}
+
+	if ev := h.Action("alert").Events(); len(ev) != 1 || ev[0].Type != watcher.Timeout {
+		t.Errorf("alert events = %+v, want one timeout", ev)
+	}
+}
+
+func TestHarnessMatches(t *testing.T) {
+	h := NewFromYAML(t, testYAML)
+
+	if got := h.Matches("assets/x.css", watcher.Delete); !slices.Equal(got, []string{"css"}) {
+		t.Errorf("Matches = %v", got)
+	}
+
+	if h.Action("missing") != nil {
+		t.Error("Action for an unknown rule should be nil")
+	}
+}
+
+func TestSource(t *testing.T) {
+	s := NewSource("src")
+	s.Emit("src/a.go", watcher.Create)
+	s.EmitError(errors.New("boom"))
+
+	if ev := <-s.Events(); ev.Path != "src/a.go" || ev.Dir != "src" {
+		t.Errorf("event = %+v", ev)
+	}
+
+	if err := <-s.Errors(); err == nil {
+		t.Error("expected queued error")
+	}
+
+	if dirs := s.WatchedDirs(); !slices.Equal(dirs, []string{"src"}) {
+		t.Errorf("WatchedDirs = %v", dirs)
+	}
+
+	_ = s.Close()
+	_ = s.Close()
+
+	if _, ok := <-s.Events(); ok {
+		t.Error("Events should be closed")
+	}
+}
+
+func TestRecordingActionErr(t *testing.T) {
+	r := &RecordingAction{Err: errors.New("fail")}
+
+	err := r.Execute(Event("a.go", watcher.Modify))
+	if err == nil || r.Count() != 1 {
+		t.Errorf("err = %v, count = %d", err, r.Count())
+	}
+
+	r.Reset()
+
+	if r.Count() != 0 {
+		t.Error("Reset should clear recorded events")
+	}
+}